@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"github.com/monopole/mdparse/internal/loader"
+	"github.com/monopole/mdparse/internal/mdast"
+	"github.com/monopole/mdparse/internal/useblue"
+	"github.com/monopole/mdparse/internal/usegold"
+	"github.com/spf13/cobra"
+)
+
+func newAstCommand(ldr *loader.FsLoader, cache *usegold.Cache) *cobra.Command {
+	var (
+		format  string
+		backend string
+	)
+	c := &cobra.Command{
+		Use:   "ast [--parser goldmark|gomarkdown] [--format text|json] {fileName|-}",
+		Short: "Show the syntax tree of a markdown file, to debug its parsing.",
+		Long: "Show the syntax tree of a markdown file, to debug its parsing.\n\n" +
+			"Each node has its kind, the range of bytes it came from, and\n" +
+			"its attributes and other properties.",
+		Example: "  mdparse ast README.md\n" +
+			"  mdparse ast --parser gomarkdown --format json README.md",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := mdast.ParseFormat(format)
+			if err != nil {
+				return err
+			}
+			var parse func([]byte) *mdast.Node
+			switch backend {
+			case "goldmark":
+				parse = func(c []byte) *mdast.Node {
+					return usegold.Ast(c, usegold.WithOptions(cache.Options))
+				}
+			case "gomarkdown":
+				parse = useblue.Ast
+			default:
+				return fmt.Errorf("unknown parser %q; use goldmark or gomarkdown", backend)
+			}
+			fld, err := loadData(ldr, args)
+			reportDiagnostics(cmd.ErrOrStderr(), ldr)
+			if err != nil {
+				return err
+			}
+			if fld == nil {
+				return fmt.Errorf("no markdown in %q", args[0])
+			}
+			if files := fld.Lessons(); len(files) != 1 {
+				return fmt.Errorf("%q holds %d files; name just one", args[0], len(files))
+			}
+			return parse(fld.Lessons()[0].C()).Write(cmd.OutOrStdout(), f)
+		},
+		SilenceUsage: true,
+	}
+	c.Flags().StringVar(
+		&format, "format", mdast.FormatText.String(),
+		"text or json.")
+	c.Flags().StringVar(
+		&backend, "parser", "goldmark",
+		"goldmark or gomarkdown.")
+	return c
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/monopole/mdparse/internal/loader"
+	"github.com/monopole/mdparse/internal/usegold"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCommand(ldr *loader.FsLoader, cache *usegold.Cache) *cobra.Command {
+	var noBlocks bool
+	c := &cobra.Command{
+		Use:   "diff {old} {new}",
+		Short: "Show the files and code blocks that differ between two trees.",
+		Example: "  mdparse diff gh:monopole/mdrip@v1.0.0/data gh:monopole/mdrip/data\n" +
+			"  mdparse diff old-docs.tar.gz docs",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			old, err := ldr.LoadTree(args[0])
+			if err != nil {
+				reportDiagnostics(cmd.ErrOrStderr(), ldr)
+				return err
+			}
+			nu, err := ldr.LoadTree(args[1])
+			reportDiagnostics(cmd.ErrOrStderr(), ldr)
+			if err != nil {
+				return err
+			}
+			var findBlocks loader.BlockFinder
+			if !noBlocks {
+				findBlocks = cache.FileBlocks
+			}
+			return loader.Diff(old, nu, findBlocks).Write(cmd.OutOrStdout())
+		},
+		SilenceUsage: true,
+	}
+	c.Flags().BoolVar(
+		&noBlocks, "no-blocks", false,
+		"Only compare file contents, not code blocks.")
+	return c
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/monopole/mdparse/internal/loader"
+	"github.com/monopole/mdparse/internal/usegold"
+	"github.com/spf13/cobra"
+)
+
+func newDumpCommand(ldr *loader.FsLoader, cache *usegold.Cache) *cobra.Command {
+	var (
+		format   string
+		noBlocks bool
+	)
+	c := &cobra.Command{
+		Use:   "dump [--format tree|json|yaml] [{fileName|-} ...]",
+		Short: "Describe the tree's files and folders, with sizes and code blocks.",
+		Example: "  mdparse dump docs\n" +
+			"  mdparse dump --format json gh:monopole/mdrip/data | jq .numFiles",
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := loader.ParseDumpFormat(format)
+			if err != nil {
+				return err
+			}
+			fld, err := loadData(ldr, args)
+			reportDiagnostics(cmd.ErrOrStderr(), ldr)
+			if err != nil {
+				return err
+			}
+			if fld == nil {
+				fld = loader.NewFolder("")
+			}
+			var findBlocks loader.BlockFinder
+			if !noBlocks {
+				findBlocks = cache.FileBlocks
+			}
+			return fld.Dump(cmd.OutOrStdout(), f, findBlocks)
+		},
+		SilenceUsage: true,
+	}
+	c.Flags().StringVar(
+		&format, "format", loader.DumpTree.String(),
+		"tree, json or yaml.")
+	c.Flags().BoolVar(
+		&noBlocks, "no-blocks", false,
+		"Don't parse files to count their code blocks.")
+	return c
+}
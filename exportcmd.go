@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"github.com/monopole/mdparse/internal/loader"
+	"github.com/monopole/mdparse/internal/usegold"
+	"github.com/monopole/mdrip/base"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+func newExportCommand(ldr *loader.FsLoader, cache *usegold.Cache) *cobra.Command {
+	var (
+		output     string
+		label      string
+		normalized bool
+	)
+	c := &cobra.Command{
+		Use:   "export --output {dir} [--label {label}] [{fileName|-} ...]",
+		Short: "Write the tree to a directory, optionally keeping only some files.",
+		Long: "Write the tree to a directory, optionally keeping only some files.\n\n" +
+			"The order of the files and folders is kept, using " + loader.OrderingFileName + "\n" +
+			"files where needed.",
+		Example: "  mdparse export --output /tmp/admin-docs --label admin docs",
+		Args:    cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" {
+				return fmt.Errorf("specify an output directory with --output")
+			}
+			fld, err := loadData(ldr, args)
+			reportDiagnostics(cmd.ErrOrStderr(), ldr)
+			if err != nil {
+				return err
+			}
+			if fld == nil {
+				return fmt.Errorf("nothing to export")
+			}
+			if label != "" {
+				fld = fld.Filter(cache.HasBlockWithLabel(base.Label(label)))
+			}
+			ex := loader.NewExporter(afero.NewOsFs())
+			ex.RestoreEncoding = !normalized
+			return ex.Export(fld, output)
+		},
+		SilenceUsage: true,
+	}
+	c.Flags().StringVarP(
+		&output, "output", "o", "",
+		"The directory to write to.")
+	c.Flags().StringVar(
+		&label, "label", "",
+		"Only export files with a code block that has this label.")
+	c.Flags().BoolVar(
+		&normalized, "normalized", false,
+		"Write files as UTF-8 with \\n line endings, rather than as they were loaded.")
+	return c
+}
@@ -0,0 +1,204 @@
+package loader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/spf13/afero"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	extZip   = ".zip"
+	extTar   = ".tar"
+	extTarGz = ".tar.gz"
+	extTgz   = ".tgz"
+)
+
+// smellsLikeArchive returns true if the path has the extension
+// of an archive that LoadArchive can read.
+func smellsLikeArchive(path string) bool {
+	p := strings.ToLower(path)
+	for _, ext := range []string{extZip, extTar, extTarGz, extTgz} {
+		if strings.HasSuffix(p, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadArchive loads the markdown found in a zip, tar or gzipped tar file.
+//
+// The archive is unpacked into an in-memory file system, which is then
+// loaded with the same filters and ordering rules used by LoadFolder.
+// The returned folder holds the root of the archive, and is named after
+// the archive (or DisplayRoot, if set).  If nothing in the archive makes
+// it through the filters, the function returns a nil folder and no error.
+//
+// Only entries that might be loaded are unpacked: those passing the file
+// filter, in folders passing the folder filter.  If the archive, or the
+// entries unpacked from it, hold more than MaxTotalBytes, or more than
+// MaxFiles files, the function returns a *LimitError.
+func (fsl *FsLoader) LoadArchive(rawPath string) (*MyFolder, error) {
+	cleanPath := filepath.Clean(rawPath)
+	info, err := fsl.fs.Stat(cleanPath)
+	if err != nil {
+		return nil, err
+	}
+	if err = fsl.checkTotalBytes(cleanPath, info.Size()); err != nil {
+		return nil, err
+	}
+	c, err := fsl.fs.ReadFile(cleanPath)
+	if err != nil {
+		return nil, err
+	}
+	memFs := afero.NewMemMapFs()
+	u := &unpacker{fsl: fsl, fs: memFs, archive: cleanPath}
+	p := strings.ToLower(cleanPath)
+	switch {
+	case strings.HasSuffix(p, extZip):
+		err = u.unpackZip(c)
+	case strings.HasSuffix(p, extTar):
+		err = u.unpackTar(bytes.NewReader(c))
+	case strings.HasSuffix(p, extTarGz) || strings.HasSuffix(p, extTgz):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(bytes.NewReader(c)); err == nil {
+			err = u.unpackTar(gz)
+		}
+	default:
+		err = fmt.Errorf("unrecognized archive type")
+	}
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to unpack %q; %w", cleanPath, err)
+	}
+	sub := fsl.withFs(memFs)
+	sub.DisplayRoot = ""
+	fld, err := sub.LoadFolder(rootSlash)
+	if err != nil || fld == nil {
+		return nil, err
+	}
+	fld.name = fsl.displayName(cleanPath)
+	// The files aren't on the loader's file system.
+	fld.diskPath = ""
+	return fld, nil
+}
+
+// archivePath converts the name of an archive entry to an absolute path
+// in the in-memory file system.  Cleaning the name after rooting it
+// keeps entries like "../../etc/passwd" inside the archive's root.
+func archivePath(name string) string {
+	return filepath.Clean(rootSlash + filepath.FromSlash(name))
+}
+
+// unpacker writes the entries of an archive that the loader might
+// load to a file system, guarding against archives that unpack to
+// more than the loader's MaxFiles or MaxTotalBytes.
+type unpacker struct {
+	fsl     *FsLoader
+	fs      afero.Fs
+	archive string
+	st      loadState
+}
+
+// wants is true if the entry with the given name might be loaded.
+func (u *unpacker) wants(name string, info os.FileInfo) bool {
+	dirs := strings.Split(filepath.Dir(archivePath(name)), rootSlash)
+	for _, d := range dirs {
+		if d != "" && u.fsl.IsAllowedFolder(&blobInfo{name: d, mode: fs.ModeDir}) != nil {
+			return false
+		}
+	}
+	return IsOrderingFile(info) || u.fsl.IsAllowedFile(info) == nil
+}
+
+func (u *unpacker) write(name string, info os.FileInfo, r io.Reader) error {
+	if u.fsl.MaxTotalBytes > 0 {
+		// Read no more than needed to know the limit is exceeded.
+		r = io.LimitReader(r, u.fsl.MaxTotalBytes-u.st.bytes+1)
+	}
+	c, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("entry %q; %w", name, err)
+	}
+	if IsOrderingFile(info) {
+		// Ordering files aren't loaded as files, but still take space.
+		u.st.bytes += int64(len(c))
+		err = u.fsl.checkTotalBytes(u.archive, u.st.bytes)
+	} else {
+		err = u.fsl.count(&u.st, u.archive, int64(len(c)))
+	}
+	if err != nil {
+		return err
+	}
+	if err = afero.WriteFile(u.fs, archivePath(name), c, 0644); err != nil {
+		return fmt.Errorf("entry %q; %w", name, err)
+	}
+	return nil
+}
+
+func (u *unpacker) unpackZip(c []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(c), int64(len(c)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			if err = u.fs.MkdirAll(archivePath(f.Name), f.Mode().Perm()|0700); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() || !u.wants(f.Name, f.FileInfo()) {
+			continue
+		}
+		var rc io.ReadCloser
+		if rc, err = f.Open(); err != nil {
+			return fmt.Errorf("entry %q; %w", f.Name, err)
+		}
+		err = u.write(f.Name, f.FileInfo(), rc)
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unpacker) unpackTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = u.fs.MkdirAll(archivePath(hdr.Name), 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if !u.wants(hdr.Name, hdr.FileInfo()) {
+				continue
+			}
+			if err = u.write(hdr.Name, hdr.FileInfo(), tr); err != nil {
+				return err
+			}
+		default:
+			// Ignore links, devices, etc.
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package loader_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type archiveEntry struct {
+	name    string
+	content []byte
+}
+
+// archiveEntries mimic makeLargeAbsFs, with an ordering file.
+// Note that there are no directory entries; they must be inferred.
+func archiveEntries() []archiveEntry {
+	return []archiveEntry{
+		{"f10.md", md[10].C()},
+		{"mmm/yyy/f09.md", md[9].C()},
+		{"mmm/f08.md", md[8].C()},
+		{"mmm/eee/f07.md", md[7].C()},
+		{"mmm/eee/f06.md", md[6].C()},
+		{"mmm/eee/ignore", []byte("not markdown")},
+		{".git/f05.md", md[5].C()},
+		{"jjj/ccc/f03.md", md[3].C()},
+		{"jjj/aaa/f01.md", md[1].C()},
+		{"jjj/" + OrderingFileName, []byte("ccc\naaa")},
+	}
+}
+
+func expectedArchiveFolder(name string) *MyFolder {
+	yyy := NewFolder("yyy").AddFileObject(md[9])
+	eee := NewFolder("eee").AddFileObject(md[6]).AddFileObject(md[7])
+	ccc := NewFolder("ccc").AddFileObject(md[3])
+	aaa := NewFolder("aaa").AddFileObject(md[1])
+	mmm := NewFolder("mmm").AddFileObject(md[8]).AddFolderObject(eee).AddFolderObject(yyy)
+	jjj := NewFolder("jjj").AddFolderObject(ccc).AddFolderObject(aaa)
+	return NewFolder(name).AddFileObject(md[10]).AddFolderObject(jjj).AddFolderObject(mmm)
+}
+
+func makeZip(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range archiveEntries() {
+		w, err := zw.Create(e.name)
+		assert.NoError(t, err)
+		_, err = w.Write(e.content)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func makeTar(t *testing.T) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range archiveEntries() {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     e.name,
+			Mode:     int64(RW),
+			Size:     int64(len(e.content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write(e.content)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func makeTarGz(t *testing.T) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(makeTar(t))
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestLoadTreeFromArchive(t *testing.T) {
+	for n, tc := range map[string]struct {
+		path string
+		make func(*testing.T) []byte
+	}{
+		"zip":   {path: "/rel/docs.zip", make: makeZip},
+		"tar":   {path: "/rel/docs.tar", make: makeTar},
+		"tarGz": {path: "/rel/docs.tar.gz", make: makeTarGz},
+		"tgz":   {path: "docs.TGZ", make: makeTarGz},
+	} {
+		t.Run(n, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			assert.NoError(t, afero.WriteFile(fs, tc.path, tc.make(t), RW))
+			fld, err := NewFsLoader(fs).LoadTree(tc.path)
+			assert.NoError(t, err)
+			expected := expectedArchiveFolder(tc.path)
+			if !assert.True(t, expected.Equals(fld)) {
+				t.Log("Loaded:")
+				fld.Accept(NewVisitorDump())
+			}
+		})
+	}
+}
+
+func TestLoadArchiveErrors(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "bad.zip", []byte("not a zip"), RW))
+	_, err := NewFsLoader(fs).LoadArchive("bad.zip")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to unpack")
+
+	_, err = NewFsLoader(fs).LoadArchive("missing.tar")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not exist")
+}
+
+func TestLoadArchiveStaysInsideRoot(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("../../escape/f01.md")
+	assert.NoError(t, err)
+	_, err = w.Write(md[1].C())
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/a/b.zip", buf.Bytes(), RW))
+	fld, err := NewFsLoader(fs).LoadTree("/a/b.zip")
+	assert.NoError(t, err)
+	expected := NewFolder("/a/b.zip").AddFolderObject(NewFolder("escape").AddFileObject(md[1]))
+	assert.True(t, expected.Equals(fld))
+	_, err = fs.Stat("/escape")
+	assert.Error(t, err)
+}
@@ -30,6 +30,21 @@ func (cb *CodeBlock) Code() string {
 	return cb.code
 }
 
+// Language is the language named in the block's opening fence, if any.
+func (cb *CodeBlock) Language() string {
+	return cb.language
+}
+
+// Parent is the file holding the block.
+func (cb *CodeBlock) Parent() *MyFile {
+	return cb.parent
+}
+
+// Labels returns the block's labels.
+func (cb *CodeBlock) Labels() []base.Label {
+	return cb.labels
+}
+
 func (cb *CodeBlock) Dump() {
 	if len(cb.labels) > 0 {
 		fmt.Print("# labels: ")
@@ -0,0 +1,157 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DiagnosticKind classifies a problem with a file or folder.
+type DiagnosticKind int
+
+const (
+	// DiagReadError is a failure to read a file or folder
+	// for a reason not covered by the other kinds.
+	DiagReadError DiagnosticKind = iota
+	// DiagPermissionDenied is a file or folder the loader may not read.
+	DiagPermissionDenied
+	// DiagBadOrderingFile is an unreadable ordering file (OrderingFileName).
+	DiagBadOrderingFile
+	// DiagInvalidEncoding is a file that's neither UTF-8 nor UTF-16.
+	DiagInvalidEncoding
+	// DiagFileTooLarge is a file bigger than FsLoader.MaxFileSize.
+	DiagFileTooLarge
+	// DiagTooDeep is a folder deeper than FsLoader.MaxDepth.
+	DiagTooDeep
+)
+
+func (k DiagnosticKind) String() string {
+	switch k {
+	case DiagReadError:
+		return "read error"
+	case DiagPermissionDenied:
+		return "permission denied"
+	case DiagBadOrderingFile:
+		return "bad ordering file"
+	case DiagInvalidEncoding:
+		return "invalid encoding"
+	case DiagFileTooLarge:
+		return "file too large"
+	case DiagTooDeep:
+		return "folder too deep"
+	default:
+		return "unknown"
+	}
+}
+
+// Diagnostic reports a problem loading a particular file or folder.
+type Diagnostic struct {
+	// Path is the file or folder's path in the loader's file system.
+	Path string
+	Kind DiagnosticKind
+	// Err is the underlying error.
+	Err error
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s %q; %v", d.Kind, d.Path, d.Err)
+}
+
+func (d *Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+// newReadDiagnostic classifies an error from reading a file or folder.
+func newReadDiagnostic(path string, err error) *Diagnostic {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return &Diagnostic{Path: path, Kind: DiagPermissionDenied, Err: err}
+	case errors.Is(err, InvalidEncodingErr):
+		return &Diagnostic{Path: path, Kind: DiagInvalidEncoding, Err: err}
+	default:
+		return &Diagnostic{Path: path, Kind: DiagReadError, Err: err}
+	}
+}
+
+// Diagnostics are the problems found while loading, in the order found.
+type Diagnostics []*Diagnostic
+
+// Summary returns a one line count of the diagnostics by kind, e.g.
+// "3 problems: 2 permission denied, 1 invalid encoding", or the empty
+// string if there are none.
+func (ds Diagnostics) Summary() string {
+	if len(ds) == 0 {
+		return ""
+	}
+	counts := make(map[DiagnosticKind]int)
+	for _, d := range ds {
+		counts[d.Kind]++
+	}
+	kinds := make([]DiagnosticKind, 0, len(counts))
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if counts[kinds[i]] != counts[kinds[j]] {
+			return counts[kinds[i]] > counts[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+	parts := make([]string, len(kinds))
+	for i, k := range kinds {
+		parts[i] = fmt.Sprintf("%d %s", counts[k], k)
+	}
+	noun := "problems"
+	if len(ds) == 1 {
+		noun = "problem"
+	}
+	return fmt.Sprintf("%d %s: %s", len(ds), noun, strings.Join(parts, ", "))
+}
+
+// diagnosticLog collects diagnostics.  It's shared by
+// a loader and the loaders made from it by withFs.
+type diagnosticLog struct {
+	mu    sync.Mutex
+	diags Diagnostics
+}
+
+// isLimit is true for problems caused by the loader's limits.
+// They're warnings rather than errors.
+func (k DiagnosticKind) isLimit() bool {
+	return k == DiagFileTooLarge || k == DiagTooDeep
+}
+
+// report handles a problem with a file or folder that can be skipped.
+// If the loader continues on error, or the problem is a limit, the
+// problem is recorded and report returns nil.  Otherwise, report
+// returns the diagnostic.
+func (fsl *FsLoader) report(st *loadState, d *Diagnostic) error {
+	if !fsl.ContinueOnError && !d.Kind.isLimit() {
+		return d
+	}
+	st.skipped = append(st.skipped, d)
+	fsl.diagLog.mu.Lock()
+	defer fsl.diagLog.mu.Unlock()
+	fsl.diagLog.diags = append(fsl.diagLog.diags, d)
+	return nil
+}
+
+// Diagnostics returns the files and folders skipped while loading, since
+// the loader was made or last reset.  Files and folders are skipped for
+// exceeding MaxFileSize or MaxDepth, and for any problem at all if
+// ContinueOnError is set.  See also MyFolder.Skipped.
+func (fsl *FsLoader) Diagnostics() Diagnostics {
+	fsl.diagLog.mu.Lock()
+	defer fsl.diagLog.mu.Unlock()
+	return append(Diagnostics(nil), fsl.diagLog.diags...)
+}
+
+// ResetDiagnostics forgets the problems reported by Diagnostics.
+func (fsl *FsLoader) ResetDiagnostics() {
+	fsl.diagLog.mu.Lock()
+	defer fsl.diagLog.mu.Unlock()
+	fsl.diagLog.diags = nil
+}
@@ -0,0 +1,96 @@
+package loader_test
+
+import (
+	"errors"
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+// deniedFs refuses to open the given paths.
+type deniedFs struct {
+	afero.Fs
+	denied map[string]bool
+}
+
+func (dfs *deniedFs) Open(name string) (afero.File, error) {
+	if dfs.denied[filepath.Clean(name)] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return dfs.Fs.Open(name)
+}
+
+func makeTroubledFs(t *testing.T) afero.Fs {
+	mfs := afero.NewMemMapFs()
+	for p, c := range map[string]string{
+		"/docs/good.md":                 "# good",
+		"/docs/latin1.md":               "caf\xE9",
+		"/docs/big.md":                  "# this file is rather large",
+		"/docs/locked.md":               "# locked",
+		"/docs/" + OrderingFileName:     "big",
+		"/docs/sub/fine.md":             "# fine",
+		"/docs/sub/" + OrderingFileName: "fine",
+		"/docs/vault/secret.md":         "# secret",
+	} {
+		assert.NoError(t, afero.WriteFile(mfs, p, []byte(c), RW))
+	}
+	return &deniedFs{Fs: mfs, denied: map[string]bool{
+		"/docs/locked.md":               true,
+		"/docs/vault":                   true,
+		"/docs/sub/" + OrderingFileName: true,
+	}}
+}
+
+func TestContinueOnError(t *testing.T) {
+	ldr := NewFsLoader(makeTroubledFs(t))
+	ldr.MaxFileSize = 20
+	_, err := ldr.LoadTree("/docs")
+	var d *Diagnostic
+	assert.True(t, errors.As(err, &d))
+	assert.Equal(t, DiagInvalidEncoding, d.Kind)
+	// Files that are too large are skipped even without ContinueOnError.
+	assert.Len(t, ldr.Diagnostics(), 1)
+	ldr.ResetDiagnostics()
+
+	ldr.ContinueOnError = true
+	fld, err := ldr.LoadTree("/docs")
+	assert.NoError(t, err)
+	assert.True(t, NewFolder("/docs").
+		AddFileObject(NewFile("good.md", []byte("# good"))).
+		AddFolderObject(NewFolder("sub").
+			AddFileObject(NewFile("fine.md", []byte("# fine")))).
+		Equals(fld))
+
+	assert.Equal(t, ldr.Diagnostics(), fld.Skipped())
+	kinds := make(map[string]DiagnosticKind)
+	for _, d := range ldr.Diagnostics() {
+		kinds[d.Path] = d.Kind
+	}
+	assert.Equal(t, map[string]DiagnosticKind{
+		"/docs/big.md":                  DiagFileTooLarge,
+		"/docs/latin1.md":               DiagInvalidEncoding,
+		"/docs/locked.md":               DiagPermissionDenied,
+		"/docs/vault":                   DiagPermissionDenied,
+		"/docs/sub/" + OrderingFileName: DiagBadOrderingFile,
+	}, kinds)
+	assert.Equal(t,
+		"5 problems: 2 permission denied, 1 bad ordering file, 1 invalid encoding, 1 file too large",
+		ldr.Diagnostics().Summary())
+	for _, d := range ldr.Diagnostics() {
+		if d.Kind == DiagPermissionDenied {
+			assert.ErrorIs(t, d, fs.ErrPermission)
+		}
+	}
+
+	ldr.ResetDiagnostics()
+	assert.Empty(t, ldr.Diagnostics())
+	assert.Equal(t, "", ldr.Diagnostics().Summary())
+
+	// A file that's asked for explicitly must load.
+	_, err = ldr.LoadTree("/docs/locked.md")
+	assert.ErrorIs(t, err, fs.ErrPermission)
+	assert.Empty(t, ldr.Diagnostics())
+}
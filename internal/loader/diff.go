@@ -0,0 +1,371 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/monopole/mdrip/base"
+	"io"
+	"path"
+	"slices"
+	"sort"
+)
+
+// ChangeKind says how a file differs between two trees.
+type ChangeKind int
+
+const (
+	// FileAdded is a file only in the new tree.
+	FileAdded ChangeKind = iota
+	// FileRemoved is a file only in the old tree.
+	FileRemoved
+	// FileMoved is a file whose content is unchanged, but whose path changed.
+	FileMoved
+	// FileModified is a file whose path is unchanged, but whose content changed.
+	FileModified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case FileAdded:
+		return "added"
+	case FileRemoved:
+		return "removed"
+	case FileMoved:
+		return "moved"
+	case FileModified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// BlockChangeKind says how a code block differs between two files.
+type BlockChangeKind int
+
+const (
+	BlockAdded BlockChangeKind = iota
+	BlockRemoved
+	BlockChanged
+)
+
+func (k BlockChangeKind) String() string {
+	switch k {
+	case BlockAdded:
+		return "added"
+	case BlockRemoved:
+		return "removed"
+	case BlockChanged:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// BlockChange is a code block that was added, removed or changed.
+type BlockChange struct {
+	Kind BlockChangeKind
+	// Old is nil for an added block, New is nil for a removed block.
+	Old, New *CodeBlock
+	// Hunks is the line-level diff of a changed block's code.
+	Hunks []*DiffHunk
+}
+
+// FileChange is a file that was added, removed, moved or modified.
+type FileChange struct {
+	Kind ChangeKind
+	// OldPath and NewPath are slash separated paths relative to the
+	// compared folders.  OldPath is empty for an added file, and NewPath
+	// is empty for a removed file.
+	OldPath, NewPath string
+	// Old is nil for an added file, New is nil for a removed file.
+	Old, New *MyFile
+	// Hunks is the line-level diff of a modified file.
+	Hunks []*DiffHunk
+	// Blocks lists the code blocks that differ, if blocks were compared.
+	// All the blocks of an added or removed file are listed.
+	Blocks []*BlockChange
+}
+
+// Path is the file's path in the new tree, or in the old if it was removed.
+func (c *FileChange) Path() string {
+	if c.NewPath != "" {
+		return c.NewPath
+	}
+	return c.OldPath
+}
+
+// BlockFinder returns the code blocks in a file, e.g. by parsing it.
+type BlockFinder func(*MyFile) []*CodeBlock
+
+// TreeDiff lists how two trees differ, ordered by path.
+type TreeDiff struct {
+	Changes []*FileChange
+}
+
+// IsEmpty is true if the trees hold the same files at the same paths.
+func (d *TreeDiff) IsEmpty() bool {
+	return len(d.Changes) == 0
+}
+
+// Diff compares two trees, matching files by their paths relative to
+// the given folders (so the folders' own names don't matter).
+//
+// A file that's gone from one path and has appeared with the same content
+// at another path is reported as moved, rather than removed and added.
+//
+// If findBlocks is not nil, it's used to compare the code blocks in
+// added, removed and modified files.  Either folder may be nil, which
+// is treated as an empty folder.
+func Diff(old, nu *MyFolder, findBlocks BlockFinder) *TreeDiff {
+	oldFiles, newFiles := filesByPath(old), filesByPath(nu)
+	d := &TreeDiff{}
+	var gone, came []string
+	for p, fi := range oldFiles {
+		other, ok := newFiles[p]
+		if !ok {
+			gone = append(gone, p)
+			continue
+		}
+		if !bytes.Equal(fi.content, other.content) {
+			d.Changes = append(d.Changes, &FileChange{
+				Kind:    FileModified,
+				OldPath: p, NewPath: p,
+				Old: fi, New: other,
+				Hunks: DiffLines(fi.content, other.content),
+			})
+		}
+	}
+	for p := range newFiles {
+		if _, ok := oldFiles[p]; !ok {
+			came = append(came, p)
+		}
+	}
+	sort.Strings(gone)
+	sort.Strings(came)
+	for _, p := range gone {
+		fi := oldFiles[p]
+		if i := findMove(p, fi, came, newFiles); i >= 0 {
+			d.Changes = append(d.Changes, &FileChange{
+				Kind:    FileMoved,
+				OldPath: p, NewPath: came[i],
+				Old: fi, New: newFiles[came[i]],
+			})
+			came = slices.Delete(came, i, i+1)
+			continue
+		}
+		d.Changes = append(d.Changes, &FileChange{
+			Kind: FileRemoved, OldPath: p, Old: fi})
+	}
+	for _, p := range came {
+		d.Changes = append(d.Changes, &FileChange{
+			Kind: FileAdded, NewPath: p, New: newFiles[p]})
+	}
+	sort.SliceStable(d.Changes, func(i, j int) bool {
+		return d.Changes[i].Path() < d.Changes[j].Path()
+	})
+	if findBlocks != nil {
+		for _, c := range d.Changes {
+			c.Blocks = diffFileBlocks(c, findBlocks)
+		}
+	}
+	return d
+}
+
+// findMove returns the index of the arrived path that the departed file
+// was moved to, or -1.  A file with the same content and name is
+// preferred.  Empty files only match files with the same name.
+func findMove(p string, fi *MyFile, came []string, files map[string]*MyFile) int {
+	result := -1
+	for i, q := range came {
+		if !bytes.Equal(fi.content, files[q].content) {
+			continue
+		}
+		if path.Base(q) == path.Base(p) {
+			return i
+		}
+		if result < 0 && len(fi.content) > 0 {
+			result = i
+		}
+	}
+	return result
+}
+
+// filesByPath maps the slash separated paths of the files
+// below the folder, relative to the folder, to the files.
+func filesByPath(fl *MyFolder) map[string]*MyFile {
+	result := make(map[string]*MyFile)
+	if fl == nil {
+		return result
+	}
+	var walk func(fl *MyFolder, prefix string)
+	walk = func(fl *MyFolder, prefix string) {
+		for _, fi := range fl.files {
+			result[prefix+fi.name] = fi
+		}
+		for _, d := range fl.dirs {
+			walk(d, prefix+d.name+"/")
+		}
+	}
+	walk(fl, "")
+	return result
+}
+
+func diffFileBlocks(c *FileChange, findBlocks BlockFinder) []*BlockChange {
+	var oldBlocks, newBlocks []*CodeBlock
+	switch c.Kind {
+	case FileMoved:
+		return nil
+	case FileAdded:
+		newBlocks = findBlocks(c.New)
+	case FileRemoved:
+		oldBlocks = findBlocks(c.Old)
+	case FileModified:
+		oldBlocks, newBlocks = findBlocks(c.Old), findBlocks(c.New)
+	}
+	return DiffBlocks(oldBlocks, newBlocks)
+}
+
+// sameBlock is true if the blocks have the same code, language and labels.
+func sameBlock(a, b *CodeBlock) bool {
+	return a.code == b.code && a.language == b.language &&
+		slices.Equal(a.labels, b.labels)
+}
+
+// DiffBlocks compares two lists of code blocks, e.g. from two versions
+// of a file.  Unchanged blocks aren't reported.  Among the blocks that
+// differ, blocks with the same name are reported as changed, as are
+// unnamed blocks found at the same position between unchanged blocks.
+// Everything else is reported as added or removed.
+func DiffBlocks(old, nu []*CodeBlock) []*BlockChange {
+	var (
+		result     []*BlockChange
+		gone, came []*CodeBlock
+	)
+	flush := func() {
+		result = append(result, pairBlocks(gone, came)...)
+		gone, came = nil, nil
+	}
+	for _, e := range diffSeq(old, nu, sameBlock) {
+		switch e.kind {
+		case editEqual:
+			flush()
+		case editDelete:
+			gone = append(gone, old[e.ia])
+		case editInsert:
+			came = append(came, nu[e.ib])
+		}
+	}
+	flush()
+	return result
+}
+
+// pairBlocks reports a run of removed and added blocks,
+// pairing them up as changed blocks where possible.
+func pairBlocks(gone, came []*CodeBlock) []*BlockChange {
+	var result []*BlockChange
+	paired := make(map[*CodeBlock]bool)
+	anonIndex := 0
+	for _, o := range gone {
+		var match *CodeBlock
+		if name := o.firstNiceLabel(); name != base.AnonLabel {
+			for _, n := range came {
+				if !paired[n] && n.firstNiceLabel() == name {
+					match = n
+					break
+				}
+			}
+		} else {
+			for ; anonIndex < len(came); anonIndex++ {
+				n := came[anonIndex]
+				if !paired[n] && n.firstNiceLabel() == base.AnonLabel {
+					match = n
+					anonIndex++
+					break
+				}
+			}
+		}
+		if match == nil {
+			result = append(result, &BlockChange{Kind: BlockRemoved, Old: o})
+			continue
+		}
+		paired[match] = true
+		result = append(result, &BlockChange{
+			Kind: BlockChanged, Old: o, New: match,
+			Hunks: DiffLines([]byte(o.code), []byte(match.code)),
+		})
+	}
+	for _, n := range came {
+		if !paired[n] {
+			result = append(result, &BlockChange{Kind: BlockAdded, New: n})
+		}
+	}
+	return result
+}
+
+// Write writes a report of the differences, e.g.
+//
+//	modified: docs/install.md
+//	@@ -3,3 +3,3 @@
+//	 ```
+//	-make install
+//	+make install PREFIX=/usr/local
+//	 ```
+//	  changed block "install"
+//	  @@ -1,1 +1,1 @@
+//	  -make install
+//	  +make install PREFIX=/usr/local
+//	moved: intro.md -> docs/intro.md
+func (d *TreeDiff) Write(w io.Writer) error {
+	for _, c := range d.Changes {
+		var err error
+		if c.Kind == FileMoved {
+			_, err = fmt.Fprintf(w, "%s: %s -> %s\n", c.Kind, c.OldPath, c.NewPath)
+		} else {
+			_, err = fmt.Fprintf(w, "%s: %s\n", c.Kind, c.Path())
+		}
+		if err != nil {
+			return err
+		}
+		for _, h := range c.Hunks {
+			if err = h.Write(w); err != nil {
+				return err
+			}
+		}
+		for _, b := range c.Blocks {
+			if err = b.write(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *BlockChange) write(w io.Writer) error {
+	cb := b.New
+	if cb == nil {
+		cb = b.Old
+	}
+	if _, err := fmt.Fprintf(w, "  %s block %q\n", b.Kind, cb.Name()); err != nil {
+		return err
+	}
+	for _, h := range b.Hunks {
+		if err := h.Write(&indenter{w: w, prefix: "  "}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indenter prefixes each line written through it.
+// It assumes every write ends with a newline.
+type indenter struct {
+	w      io.Writer
+	prefix string
+}
+
+func (in *indenter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(in.w, in.prefix); err != nil {
+		return 0, err
+	}
+	return in.w.Write(p)
+}
@@ -0,0 +1,113 @@
+package loader_test
+
+import (
+	"bytes"
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/monopole/mdrip/base"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// fakeBlocks treats each line "name|code" as a code block,
+// labelled with the name if there is one.
+func fakeBlocks(fi *MyFile) []*CodeBlock {
+	var result []*CodeBlock
+	for _, line := range strings.Split(string(fi.C()), "\n") {
+		name, code, ok := strings.Cut(line, "|")
+		if !ok {
+			continue
+		}
+		cb := NewCodeBlock(fi, code+"\n", "bash")
+		if name != "" {
+			cb.AddLabels([]base.Label{base.Label(name)})
+		}
+		result = append(result, cb)
+	}
+	return result
+}
+
+func TestDiff(t *testing.T) {
+	old := NewFolder("old").
+		AddFileObject(NewFile("keep.md", []byte("same\n"))).
+		AddFileObject(NewFile("gone.md", []byte("bye\n"))).
+		AddFileObject(NewFile("edit.md", []byte("one\ntwo\nthree\n"))).
+		AddFolderObject(NewFolder("a").
+			AddFileObject(NewFile("wander.md", []byte("roam\n"))))
+	nu := NewFolder("new").
+		AddFileObject(NewFile("keep.md", []byte("same\n"))).
+		AddFileObject(NewFile("edit.md", []byte("one\n2\nthree\n"))).
+		AddFileObject(NewFile("fresh.md", []byte("hi\n"))).
+		AddFolderObject(NewFolder("b").
+			AddFileObject(NewFile("wander.md", []byte("roam\n"))))
+
+	d := Diff(old, nu, nil)
+	var got []string
+	for _, c := range d.Changes {
+		got = append(got, c.Kind.String()+" "+c.OldPath+" "+c.NewPath)
+	}
+	assert.Equal(t, []string{
+		"moved a/wander.md b/wander.md",
+		"modified edit.md edit.md",
+		"added  fresh.md",
+		"removed gone.md ",
+	}, got)
+
+	var buf bytes.Buffer
+	assert.NoError(t, d.Write(&buf))
+	assert.Equal(t, `moved: a/wander.md -> b/wander.md
+modified: edit.md
+@@ -1,3 +1,3 @@
+ one
+-two
++2
+ three
+added: fresh.md
+removed: gone.md
+`, buf.String())
+
+	assert.True(t, Diff(old, old, nil).IsEmpty())
+	assert.Len(t, Diff(nil, old, nil).Changes, 4)
+}
+
+func TestDiffWithBlocks(t *testing.T) {
+	old := NewFolder("old").
+		AddFileObject(NewFile("steps.md", []byte(
+			"intro\nbuild|make\n|echo one\ntest|go test\n|echo two\n")))
+	nu := NewFolder("new").
+		AddFileObject(NewFile("steps.md", []byte(
+			"intro\nbuild|make all\n|echo uno\n|echo two\ndeploy|kubectl apply\n")))
+	d := Diff(old, nu, fakeBlocks)
+	if !assert.Len(t, d.Changes, 1) {
+		return
+	}
+	var got []string
+	for _, b := range d.Changes[0].Blocks {
+		cb := b.New
+		if cb == nil {
+			cb = b.Old
+		}
+		got = append(got, b.Kind.String()+" "+cb.Name()+" "+strings.TrimSpace(cb.Code()))
+	}
+	assert.Equal(t, []string{
+		"changed build make all",
+		"changed clickToCopy echo uno",
+		"removed test go test",
+		"added deploy kubectl apply",
+	}, got)
+
+	var buf bytes.Buffer
+	assert.NoError(t, d.Write(&buf))
+	assert.Contains(t, buf.String(), `  changed block "build"
+  @@ -1,1 +1,1 @@
+  -make
+  +make all
+`)
+
+	// All the blocks of a new file are added.
+	d = Diff(nil, nu, fakeBlocks)
+	assert.Len(t, d.Changes[0].Blocks, 4)
+	for _, b := range d.Changes[0].Blocks {
+		assert.Equal(t, BlockAdded, b.Kind)
+	}
+}
@@ -0,0 +1,63 @@
+package loader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// DigestAlgorithm names the hash used by Digest.
+const DigestAlgorithm = "sha256"
+
+// Digest is the hex encoded hash of the file's content.
+// The file's name doesn't contribute to it.
+func (fi *MyFile) Digest() string {
+	sum := sha256.Sum256(fi.content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Digest is a Merkle-style hash of everything below the folder: the
+// hash of the names and digests of the folder's files and folders,
+// in order.  The folder's own name doesn't contribute to it, so two
+// folders have the same digest if they'd hold the same files at the
+// same relative paths, in the same order.
+//
+// Renaming, reordering, adding, removing or changing any file or folder
+// below the folder changes the digest.
+func (fl *MyFolder) Digest() string {
+	d, _ := fl.digest("", nil)
+	return d
+}
+
+// digest returns the folder's digest and the number of bytes in the
+// files below it.  If the manifest isn't nil, entries for everything
+// below the folder are added to it, with paths starting with the prefix.
+func (fl *MyFolder) digest(prefix string, m *Manifest) (string, int) {
+	h := sha256.New()
+	size := 0
+	for _, fi := range fl.files {
+		d := fi.Digest()
+		if m != nil {
+			m.Entries = append(m.Entries, ManifestEntry{
+				Path: prefix + fi.name, Size: len(fi.content), Digest: d})
+		}
+		size += len(fi.content)
+		_, _ = fmt.Fprintf(h, "file %s %q\n", d, fi.name)
+	}
+	for _, sub := range fl.dirs {
+		i := -1
+		if m != nil {
+			// Reserve a spot, so the folder precedes its contents.
+			i = len(m.Entries)
+			m.Entries = append(m.Entries, ManifestEntry{
+				Path: prefix + sub.name, Folder: true})
+		}
+		d, n := sub.digest(prefix+sub.name+"/", m)
+		if m != nil {
+			m.Entries[i].Size, m.Entries[i].Digest = n, d
+		}
+		size += n
+		_, _ = fmt.Fprintf(h, "folder %s %q\n", d, sub.name)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size
+}
@@ -0,0 +1,219 @@
+package loader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/monopole/mdrip/base"
+	"gopkg.in/yaml.v3"
+	"io"
+	"slices"
+	"strings"
+)
+
+// DumpFormat is a way of describing a tree; see MyFolder.Dump.
+type DumpFormat int
+
+const (
+	// DumpTree is a text tree drawn with box-drawing characters.
+	DumpTree DumpFormat = iota
+	DumpJSON
+	DumpYAML
+)
+
+func (f DumpFormat) String() string {
+	switch f {
+	case DumpTree:
+		return "tree"
+	case DumpJSON:
+		return "json"
+	case DumpYAML:
+		return "yaml"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseDumpFormat returns the format with the given name.
+func ParseDumpFormat(s string) (DumpFormat, error) {
+	for _, f := range []DumpFormat{DumpTree, DumpJSON, DumpYAML} {
+		if strings.EqualFold(s, f.String()) {
+			return f, nil
+		}
+	}
+	return DumpTree, fmt.Errorf("unknown dump format %q; use tree, json or yaml", s)
+}
+
+// dumpFolder describes a folder in a dump.
+type dumpFolder struct {
+	Name string `json:"name" yaml:"name"`
+	// NumFiles and Size count everything below the folder.
+	NumFiles int           `json:"numFiles" yaml:"numFiles"`
+	Size     int           `json:"size" yaml:"size"`
+	Blocks   int           `json:"blocks,omitempty" yaml:"blocks,omitempty"`
+	Files    []*dumpFile   `json:"files,omitempty" yaml:"files,omitempty"`
+	Folders  []*dumpFolder `json:"folders,omitempty" yaml:"folders,omitempty"`
+}
+
+// dumpFile describes a file in a dump.
+type dumpFile struct {
+	Name   string   `json:"name" yaml:"name"`
+	Size   int      `json:"size" yaml:"size"`
+	Blocks int      `json:"blocks,omitempty" yaml:"blocks,omitempty"`
+	Labels []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+func newDumpFile(fi *MyFile, findBlocks BlockFinder) *dumpFile {
+	result := &dumpFile{Name: fi.name, Size: len(fi.content)}
+	if findBlocks == nil {
+		return result
+	}
+	blocks := findBlocks(fi)
+	result.Blocks = len(blocks)
+	for _, b := range blocks {
+		for _, l := range b.Labels() {
+			if l == base.WildCardLabel || l == base.AnonLabel ||
+				slices.Contains(result.Labels, string(l)) {
+				continue
+			}
+			result.Labels = append(result.Labels, string(l))
+		}
+	}
+	return result
+}
+
+func newDumpFolder(fl *MyFolder, findBlocks BlockFinder) *dumpFolder {
+	var stack []*dumpFolder
+	_ = (&Walker{
+		EnterFolder: func(_ *WalkState, fl *MyFolder) error {
+			stack = append(stack, &dumpFolder{Name: fl.name})
+			return nil
+		},
+		VisitFile: func(_ *WalkState, fi *MyFile) error {
+			d, f := stack[len(stack)-1], newDumpFile(fi, findBlocks)
+			d.Files = append(d.Files, f)
+			d.NumFiles++
+			d.Size += f.Size
+			d.Blocks += f.Blocks
+			return nil
+		},
+		LeaveFolder: func(_ *WalkState, _ *MyFolder) error {
+			if len(stack) == 1 {
+				return nil
+			}
+			d, up := stack[len(stack)-1], stack[len(stack)-2]
+			up.Folders = append(up.Folders, d)
+			up.NumFiles += d.NumFiles
+			up.Size += d.Size
+			up.Blocks += d.Blocks
+			stack = stack[:len(stack)-1]
+			return nil
+		},
+	}).Walk(context.Background(), fl)
+	return stack[0]
+}
+
+// Dump writes a description of the tree below the folder: names, file
+// sizes, and, if findBlocks isn't nil, the number of code blocks in each
+// file and their labels.  Folder sizes and block counts are totals.
+func (fl *MyFolder) Dump(w io.Writer, format DumpFormat, findBlocks BlockFinder) error {
+	d := newDumpFolder(fl, findBlocks)
+	switch format {
+	case DumpTree:
+		return d.writeTree(w, findBlocks != nil)
+	case DumpJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case DumpYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("unknown dump format %d", format)
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// writeTree writes the folder in the style of the tree command, e.g.
+//
+//	benelux (3 files, 120 bytes)
+//	├── README.md (40 bytes)
+//	└── belgium/ (2 files, 80 bytes)
+//	    ├── beer.md (40 bytes)
+//	    └── tintin.md (40 bytes)
+func (d *dumpFolder) writeTree(w io.Writer, withBlocks bool) error {
+	tw := &treeWriter{w: w, withBlocks: withBlocks}
+	tw.folder("", "", d)
+	return tw.err
+}
+
+// treeWriter remembers the first write error, so the
+// tree can be written without checking each line.
+type treeWriter struct {
+	w          io.Writer
+	withBlocks bool
+	err        error
+}
+
+func (tw *treeWriter) line(format string, args ...any) {
+	if tw.err == nil {
+		_, tw.err = fmt.Fprintf(tw.w, format+"\n", args...)
+	}
+}
+
+// folder writes a line for the folder after the given branch,
+// then lines for its contents, each after the indent.
+func (tw *treeWriter) folder(branch, indent string, d *dumpFolder) {
+	name := d.Name
+	if branch != "" {
+		name += "/"
+	}
+	summary := plural(d.NumFiles, "file") + ", " + plural(d.Size, "byte")
+	if tw.withBlocks {
+		summary += ", " + plural(d.Blocks, "block")
+	}
+	tw.line("%s%s (%s)", branch, name, summary)
+	n := len(d.Files) + len(d.Folders)
+	for i, f := range d.Files {
+		tw.file(indent+treeBranch(i == n-1), f)
+	}
+	for i, sub := range d.Folders {
+		last := len(d.Files)+i == n-1
+		tw.folder(indent+treeBranch(last), indent+treeIndent(last), sub)
+	}
+}
+
+func (tw *treeWriter) file(branch string, f *dumpFile) {
+	summary := plural(f.Size, "byte")
+	if tw.withBlocks {
+		summary += ", " + plural(f.Blocks, "block")
+		if len(f.Labels) > 0 {
+			summary += ": " + strings.Join(f.Labels, ", ")
+		}
+	}
+	tw.line("%s%s (%s)", branch, f.Name, summary)
+}
+
+func treeBranch(last bool) string {
+	if last {
+		return "└── "
+	}
+	return "├── "
+}
+
+func treeIndent(last bool) string {
+	if last {
+		return "    "
+	}
+	return "│   "
+}
@@ -0,0 +1,117 @@
+package loader_test
+
+import (
+	"bytes"
+	"encoding/json"
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"strings"
+	"testing"
+)
+
+func makeDumpable() *MyFolder {
+	return NewFolder("docs").
+		AddFileObject(NewFile("README.md", []byte("# hi\n"))).
+		AddFileObject(NewFile("setup.md", []byte("install|make\ntest|go test\n|ls\ninstall|again\n"))).
+		AddFolderObject(NewFolder("deep").
+			AddFolderObject(NewFolder("deeper").
+				AddFileObject(NewFile("a.md", []byte("run|true\n")))).
+			AddFileObject(NewEmptyFile("b.md")))
+}
+
+func TestDumpTree(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, makeDumpable().Dump(&b, DumpTree, fakeBlocks))
+	assert.Equal(t, `docs (4 files, 58 bytes, 5 blocks)
+├── README.md (5 bytes, 0 blocks)
+├── setup.md (44 bytes, 4 blocks: install, test)
+└── deep/ (2 files, 9 bytes, 1 block)
+    ├── b.md (0 bytes, 0 blocks)
+    └── deeper/ (1 file, 9 bytes, 1 block)
+        └── a.md (9 bytes, 1 block: run)
+`, b.String())
+
+	b.Reset()
+	assert.NoError(t, makeDumpable().Dump(&b, DumpTree, nil))
+	assert.Equal(t, `docs (4 files, 58 bytes)
+├── README.md (5 bytes)
+├── setup.md (44 bytes)
+└── deep/ (2 files, 9 bytes)
+    ├── b.md (0 bytes)
+    └── deeper/ (1 file, 9 bytes)
+        └── a.md (9 bytes)
+`, b.String())
+}
+
+func TestDumpStructured(t *testing.T) {
+	type file struct {
+		Name   string
+		Size   int
+		Blocks int
+		Labels []string
+	}
+	type folder struct {
+		Name     string
+		NumFiles int `yaml:"numFiles"`
+		Size     int
+		Blocks   int
+		Files    []file
+		Folders  []folder
+	}
+	want := folder{
+		Name: "docs", NumFiles: 4, Size: 58, Blocks: 5,
+		Files: []file{
+			{Name: "README.md", Size: 5},
+			{Name: "setup.md", Size: 44, Blocks: 4, Labels: []string{"install", "test"}},
+		},
+		Folders: []folder{{
+			Name: "deep", NumFiles: 2, Size: 9, Blocks: 1,
+			Files: []file{{Name: "b.md"}},
+			Folders: []folder{{
+				Name: "deeper", NumFiles: 1, Size: 9, Blocks: 1,
+				Files: []file{{Name: "a.md", Size: 9, Blocks: 1, Labels: []string{"run"}}},
+			}},
+		}},
+	}
+	var b bytes.Buffer
+	assert.NoError(t, makeDumpable().Dump(&b, DumpJSON, fakeBlocks))
+	var got folder
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &got))
+	assert.Equal(t, want, got)
+
+	b.Reset()
+	assert.NoError(t, makeDumpable().Dump(&b, DumpYAML, fakeBlocks))
+	got = folder{}
+	assert.NoError(t, yaml.Unmarshal(b.Bytes(), &got))
+	assert.Equal(t, want, got)
+}
+
+func TestVisitorDumpDeep(t *testing.T) {
+	// Deeper than VisitorDump's old, fixed indentation.
+	top := NewFolder("top")
+	fl := top
+	for i := 0; i < 40; i++ {
+		sub := NewFolder("d")
+		fl.AddFolderObject(sub)
+		fl = sub
+	}
+	fl.AddFileObject(NewFile("x.md", []byte("# x")))
+	var b bytes.Buffer
+	top.Accept(NewVisitorDumpTo(&b))
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	assert.Len(t, lines, 42)
+	assert.Equal(t, strings.Repeat("  ", 41)+"x.md : # x...", lines[41])
+}
+
+func TestParseDumpFormat(t *testing.T) {
+	for s, want := range map[string]DumpFormat{
+		"tree": DumpTree, "json": DumpJSON, "YAML": DumpYAML,
+	} {
+		f, err := ParseDumpFormat(s)
+		assert.NoError(t, err)
+		assert.Equal(t, want, f)
+	}
+	_, err := ParseDumpFormat("xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var InvalidEncodingErr = fmt.Errorf("not valid UTF-8 or UTF-16")
+
+// Encoding is the character encoding of a file as stored.
+type Encoding int
+
+const (
+	// EncodingUTF8 is UTF-8 with no byte order mark.
+	EncodingUTF8 Encoding = iota
+	// EncodingUTF8BOM is UTF-8 preceded by a byte order mark.
+	EncodingUTF8BOM
+	// EncodingUTF16LE is little endian UTF-16, with a byte order mark.
+	EncodingUTF16LE
+	// EncodingUTF16BE is big endian UTF-16, with a byte order mark.
+	EncodingUTF16BE
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingUTF8:
+		return "utf-8"
+	case EncodingUTF8BOM:
+		return "utf-8-bom"
+	case EncodingUTF16LE:
+		return "utf-16le"
+	case EncodingUTF16BE:
+		return "utf-16be"
+	default:
+		return "unknown"
+	}
+}
+
+// LineEnding is the line ending used in a file as stored.
+type LineEnding int
+
+const (
+	// LineEndingLF is "\n", as used on Unix.
+	LineEndingLF LineEnding = iota
+	// LineEndingCRLF is "\r\n", as used on Windows.
+	LineEndingCRLF
+	// LineEndingCR is "\r", as used on classic Mac OS.
+	LineEndingCR
+)
+
+func (le LineEnding) String() string {
+	switch le {
+	case LineEndingLF:
+		return "lf"
+	case LineEndingCRLF:
+		return "crlf"
+	case LineEndingCR:
+		return "cr"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Normalization records how a file's content was changed when loaded.
+// The zero value means the content was loaded as is.
+type Normalization struct {
+	Encoding   Encoding
+	LineEnding LineEnding
+	// MixedLineEndings is true if the content had more than one kind of
+	// line ending.  All of them were converted, but only LineEnding is
+	// used to restore the content.
+	MixedLineEndings bool
+}
+
+// IsZero is true if the content was loaded as is.
+func (n Normalization) IsZero() bool {
+	return n == Normalization{}
+}
+
+func (n Normalization) String() string {
+	return n.Encoding.String() + "/" + n.LineEnding.String()
+}
+
+// Normalize converts raw file content to UTF-8 with "\n" line endings
+// and no byte order mark, which is what the markdown parsers expect.
+//
+// UTF-16 is recognized by its byte order mark.  The line ending recorded
+// is the most common one in the file; a file with a mix of line endings
+// gets them all converted, but will be restored with just the one.
+// Content that's neither UTF-8 nor UTF-16 gets an InvalidEncodingErr.
+func Normalize(raw []byte) ([]byte, Normalization, error) {
+	var (
+		n   Normalization
+		c   = raw
+		err error
+	)
+	switch {
+	case bytes.HasPrefix(raw, bomUTF8):
+		n.Encoding = EncodingUTF8BOM
+		c = raw[len(bomUTF8):]
+	case bytes.HasPrefix(raw, bomUTF16LE):
+		n.Encoding = EncodingUTF16LE
+		c, err = decodeUTF16(raw[len(bomUTF16LE):], binary.LittleEndian)
+	case bytes.HasPrefix(raw, bomUTF16BE):
+		n.Encoding = EncodingUTF16BE
+		c, err = decodeUTF16(raw[len(bomUTF16BE):], binary.BigEndian)
+	}
+	if err != nil {
+		return nil, Normalization{}, err
+	}
+	if !utf8.Valid(c) {
+		return nil, Normalization{}, InvalidEncodingErr
+	}
+	if bytes.IndexByte(c, '\r') < 0 {
+		return c, n, nil
+	}
+	n.LineEnding, n.MixedLineEndings = commonLineEnding(c)
+	c = bytes.ReplaceAll(c, []byte("\r\n"), []byte("\n"))
+	c = bytes.ReplaceAll(c, []byte("\r"), []byte("\n"))
+	return c, n, nil
+}
+
+// commonLineEnding returns the most common line ending in the content,
+// preferring LF then CRLF in a tie, and whether there was more than one.
+func commonLineEnding(c []byte) (LineEnding, bool) {
+	crlf := bytes.Count(c, []byte("\r\n"))
+	cr := bytes.Count(c, []byte("\r")) - crlf
+	lf := bytes.Count(c, []byte("\n")) - crlf
+	kinds := 0
+	for _, x := range []int{crlf, cr, lf} {
+		if x > 0 {
+			kinds++
+		}
+	}
+	switch {
+	case crlf > lf && crlf >= cr:
+		return LineEndingCRLF, kinds > 1
+	case cr > lf && cr > crlf:
+		return LineEndingCR, kinds > 1
+	default:
+		return LineEndingLF, kinds > 1
+	}
+}
+
+func decodeUTF16(b []byte, order binary.ByteOrder) ([]byte, error) {
+	if len(b)%2 != 0 {
+		return nil, InvalidEncodingErr
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = order.Uint16(b[2*i:])
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(u); i++ {
+		r := rune(u[i])
+		if utf16.IsSurrogate(r) {
+			if i+1 == len(u) {
+				return nil, InvalidEncodingErr
+			}
+			if r = utf16.DecodeRune(r, rune(u[i+1])); r == utf8.RuneError {
+				return nil, InvalidEncodingErr
+			}
+			i++
+		}
+		buf.WriteRune(r)
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeUTF16(c []byte, bom []byte, order binary.AppendByteOrder) []byte {
+	u := utf16.Encode([]rune(string(c)))
+	result := make([]byte, len(bom), len(bom)+2*len(u))
+	copy(result, bom)
+	for _, x := range u {
+		result = order.AppendUint16(result, x)
+	}
+	return result
+}
+
+// Restore reverses the normalization, converting normalized content
+// back to the line endings and encoding it was stored with.
+func (n Normalization) Restore(c []byte) []byte {
+	switch n.LineEnding {
+	case LineEndingCRLF:
+		c = bytes.ReplaceAll(c, []byte("\n"), []byte("\r\n"))
+	case LineEndingCR:
+		c = bytes.ReplaceAll(c, []byte("\n"), []byte("\r"))
+	}
+	switch n.Encoding {
+	case EncodingUTF8BOM:
+		c = append(append([]byte{}, bomUTF8...), c...)
+	case EncodingUTF16LE:
+		c = encodeUTF16(c, bomUTF16LE, binary.LittleEndian)
+	case EncodingUTF16BE:
+		c = encodeUTF16(c, bomUTF16BE, binary.BigEndian)
+	}
+	return c
+}
+
+// newLoadedFile returns a file holding the normalized content.
+func newLoadedFile(name string, raw []byte) (*MyFile, error) {
+	c, n, err := Normalize(raw)
+	if err != nil {
+		return nil, err
+	}
+	fi := NewFile(name, c)
+	fi.norm = n
+	return fi, nil
+}
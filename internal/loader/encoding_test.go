@@ -0,0 +1,77 @@
+package loader_test
+
+import (
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for n, tc := range map[string]struct {
+		raw  string
+		want Normalization
+	}{
+		"plain":   {raw: "# hi\n```\nls\n```\n"},
+		"bom":     {raw: "\xEF\xBB\xBF# hi\n```\nls\n```\n", want: Normalization{Encoding: EncodingUTF8BOM}},
+		"crlf":    {raw: "# hi\r\n```\r\nls\r\n```\r\n", want: Normalization{LineEnding: LineEndingCRLF}},
+		"cr":      {raw: "# hi\r```\rls\r```\r", want: Normalization{LineEnding: LineEndingCR}},
+		"bomCrlf": {raw: "\xEF\xBB\xBF# hi\r\n```\r\nls\r\n```\r\n", want: Normalization{Encoding: EncodingUTF8BOM, LineEnding: LineEndingCRLF}},
+		"utf16le": {raw: "\xFF\xFE#\x00 \x00h\x00i\x00\n\x00`\x00`\x00`\x00\n\x00l\x00s\x00\n\x00`\x00`\x00`\x00\n\x00", want: Normalization{Encoding: EncodingUTF16LE}},
+		"utf16be": {raw: "\xFE\xFF\x00#\x00 \x00h\x00i\x00\r\x00\n\x00`\x00`\x00`\x00\r\x00\n\x00l\x00s\x00\r\x00\n\x00`\x00`\x00`\x00\r\x00\n", want: Normalization{Encoding: EncodingUTF16BE, LineEnding: LineEndingCRLF}},
+	} {
+		t.Run(n, func(t *testing.T) {
+			c, norm, err := Normalize([]byte(tc.raw))
+			assert.NoError(t, err)
+			assert.Equal(t, "# hi\n```\nls\n```\n", string(c))
+			assert.Equal(t, tc.want, norm)
+			assert.Equal(t, tc.want.IsZero(), norm.IsZero())
+			assert.Equal(t, tc.raw, string(norm.Restore(c)))
+		})
+	}
+}
+
+func TestNormalizeOddities(t *testing.T) {
+	c, norm, err := Normalize([]byte("a\r\nb\nc\r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb\nc\n", string(c))
+	assert.Equal(t, Normalization{LineEnding: LineEndingCRLF, MixedLineEndings: true}, norm)
+	assert.Equal(t, "a\r\nb\r\nc\r\n", string(norm.Restore(c)))
+
+	// A character outside the basic multilingual plane.
+	c, norm, err = Normalize([]byte("\xFF\xFE\x3D\xD8\x00\xDE"))
+	assert.NoError(t, err)
+	assert.Equal(t, "😀", string(c))
+	assert.Equal(t, "\xFF\xFE\x3D\xD8\x00\xDE", string(norm.Restore(c)))
+
+	for n, raw := range map[string]string{
+		"latin1":        "caf\xE9",
+		"oddUtf16":      "\xFF\xFEa",
+		"loneSurrogate": "\xFF\xFE\x3D\xD8",
+	} {
+		_, _, err = Normalize([]byte(raw))
+		assert.ErrorIs(t, err, InvalidEncodingErr, n)
+	}
+}
+
+func TestLoadNormalizes(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/docs/win.md", []byte("\xEF\xBB\xBF```\r\nls\r\n```\r\n"), RW))
+	assert.NoError(t, afero.WriteFile(fs, "/docs/"+OrderingFileName, []byte("win\r\n"), RW))
+	fld, err := NewFsLoader(fs).LoadTree("/docs")
+	assert.NoError(t, err)
+	fi := fld.Lookup("win").(*MyFile)
+	assert.Equal(t, "```\nls\n```\n", string(fi.C()))
+	assert.Equal(t,
+		Normalization{Encoding: EncodingUTF8BOM, LineEnding: LineEndingCRLF},
+		fi.Normalization())
+
+	fld, err = NewFsLoader(fs).LoadTree("/docs/win.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "```\nls\n```\n", string(fld.Lookup("win").(*MyFile).C()))
+
+	assert.NoError(t, afero.WriteFile(fs, "/docs/bad.md", []byte("caf\xE9"), RW))
+	_, err = NewFsLoader(fs).LoadTree("/docs")
+	assert.ErrorIs(t, err, InvalidEncodingErr)
+	assert.Contains(t, err.Error(), "bad.md")
+}
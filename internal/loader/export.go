@@ -0,0 +1,103 @@
+package loader
+
+import (
+	"fmt"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Exporter writes trees to a file system.
+type Exporter struct {
+	// Transform, if not nil, is given each file and its content,
+	// and returns the content to write.
+	Transform func(fi *MyFile, c []byte) ([]byte, error)
+
+	// RestoreEncoding, if true, means files are written with the
+	// encoding and line endings they were loaded with.
+	// See MyFile.Normalization.
+	RestoreEncoding bool
+
+	fs *afero.Afero
+}
+
+// NewExporter returns an exporter that writes to the given file system,
+// restoring encodings.  To write a subset of a tree, use MyFolder.Filter
+// before exporting it.
+func NewExporter(fs afero.Fs) *Exporter {
+	return &Exporter{RestoreEncoding: true, fs: &afero.Afero{Fs: fs}}
+}
+
+// Export writes the files below the folder to the directory, making
+// directories as needed and replacing files that already exist.
+// The folder's own name doesn't matter.
+//
+// If the order of a folder's files and folders isn't the order that
+// LoadFolder would give them by default, an ordering file
+// (OrderingFileName) is written to keep the order.  A stale ordering
+// file is removed.  Note that LoadFolder always puts a README.md first.
+func (ex *Exporter) Export(fl *MyFolder, dir string) error {
+	if err := ex.fs.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, fi := range fl.files {
+		if err := checkName(fi.name); err != nil {
+			return err
+		}
+		c := fi.content
+		if ex.Transform != nil {
+			var err error
+			if c, err = ex.Transform(fi, c); err != nil {
+				return fmt.Errorf("unable to transform %q; %w", fi.FullName(), err)
+			}
+		}
+		if ex.RestoreEncoding {
+			c = fi.norm.Restore(c)
+		}
+		if err := ex.fs.WriteFile(filepath.Join(dir, fi.name), c, 0644); err != nil {
+			return err
+		}
+	}
+	for _, d := range fl.dirs {
+		if err := checkName(d.name); err != nil {
+			return err
+		}
+		if err := ex.Export(d, filepath.Join(dir, d.name)); err != nil {
+			return err
+		}
+	}
+	return ex.writeOrdering(fl, filepath.Join(dir, OrderingFileName))
+}
+
+// writeOrdering writes an ordering file, or removes it if it's not needed.
+func (ex *Exporter) writeOrdering(fl *MyFolder, path string) error {
+	if hasDefaultOrder(fl) {
+		if err := ex.fs.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var names []string
+	for _, fi := range fl.files {
+		names = append(names, fi.name)
+	}
+	for _, d := range fl.dirs {
+		names = append(names, d.name)
+	}
+	return ex.fs.WriteFile(path, []byte(strings.Join(names, "\n")+"\n"), 0644)
+}
+
+// hasDefaultOrder is true if the folder's files and folders are in
+// the order LoadFolder would give them without an ordering file.
+func hasDefaultOrder(fl *MyFolder) bool {
+	files := slices.Clone(fl.files)
+	slices.SortFunc(files, func(a, b *MyFile) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return slices.Equal(ReorderFiles(files, nil), fl.files) &&
+		slices.IsSortedFunc(fl.dirs, func(a, b *MyFolder) int {
+			return strings.Compare(a.name, b.name)
+		})
+}
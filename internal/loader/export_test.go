@@ -0,0 +1,68 @@
+package loader_test
+
+import (
+	"bytes"
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	makeLargeAbsFs(t, fs)
+	orig, err := NewFsLoader(fs).LoadTree("/")
+	assert.NoError(t, err)
+
+	out := afero.NewMemMapFs()
+	assert.NoError(t, NewExporter(out).Export(orig, "/out"))
+	fld, err := NewFsLoader(out).LoadTree("/out")
+	assert.NoError(t, err)
+	assert.Equal(t, orig.Digest(), fld.Digest())
+
+	// Sort changes the order, so ordering files are needed to keep it.
+	orig.Sort(func(a, b *MyFile) int { return -strings.Compare(a.Name(), b.Name()) },
+		func(a, b *MyFolder) int { return -strings.Compare(a.Name(), b.Name()) })
+	assert.NoError(t, NewExporter(out).Export(orig, "/out"))
+	c, err := afero.ReadFile(out, "/out/"+OrderingFileName)
+	assert.NoError(t, err)
+	assert.True(t, len(c) > 0)
+	fld, err = NewFsLoader(out).LoadTree("/out")
+	assert.NoError(t, err)
+	assert.Equal(t, orig.Digest(), fld.Digest())
+
+	// Back to the default order, so the ordering files go away.
+	orig.Sort(func(a, b *MyFile) int { return strings.Compare(a.Name(), b.Name()) },
+		func(a, b *MyFolder) int { return strings.Compare(a.Name(), b.Name()) })
+	assert.NoError(t, NewExporter(out).Export(orig, "/out"))
+	_, err = out.Stat("/out/" + OrderingFileName)
+	assert.Error(t, err)
+}
+
+func TestExportRestoresAndTransforms(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	raw := "\xEF\xBB\xBF# win\r\nkeep\r\ndrop\r\n"
+	assert.NoError(t, afero.WriteFile(fs, "/in/win.md", []byte(raw), RW))
+	assert.NoError(t, afero.WriteFile(fs, "/in/other.md", []byte("# other"), RW))
+	fld, err := NewFsLoader(fs).LoadTree("/in")
+	assert.NoError(t, err)
+
+	assert.NoError(t, NewExporter(fs).Export(fld, "/same"))
+	c, err := afero.ReadFile(fs, "/same/win.md")
+	assert.NoError(t, err)
+	assert.Equal(t, raw, string(c))
+
+	ex := NewExporter(fs)
+	ex.RestoreEncoding = false
+	ex.Transform = func(fi *MyFile, c []byte) ([]byte, error) {
+		return bytes.ReplaceAll(c, []byte("drop\n"), nil), nil
+	}
+	subset := fld.Filter(func(fi *MyFile) bool { return fi.Name() == "win.md" })
+	assert.NoError(t, ex.Export(subset, "/subset"))
+	c, err = afero.ReadFile(fs, "/subset/win.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "# win\nkeep\n", string(c))
+	_, err = fs.Stat("/subset/other.md")
+	assert.Error(t, err)
+}
@@ -1,17 +1,67 @@
 package loader
 
 import (
+	"errors"
 	"fmt"
 	"github.com/spf13/afero"
 	"os"
 	"path/filepath"
-	"strings"
+	"time"
 )
 
 // FsLoader navigates and reads a file system.
 type FsLoader struct {
 	IsAllowedFile, IsAllowedFolder filter
-	fs                             *afero.Afero
+
+	// DisplayRoot, if not empty, replaces the name of the folder returned
+	// by LoadFolder, and so determines how that folder and everything in it
+	// is shown by Name and FullName.  Use it to give a friendly name to
+	// paths like "../shared-docs" or "/var/tmp/xyz123".  The folder still
+	// knows where it was loaded from, so MyFile.Load works.
+	DisplayRoot string
+
+	// CloneCacheDir is where repositories cloned by CloneAndLoadRepo
+	// are kept, so they needn't be fetched again.  If empty, clones go to
+	// temporary directories that are removed after loading.
+	CloneCacheDir string
+
+	// CloneCacheMaxAge is how long a clone in the CloneCacheDir may go
+	// unused before it's removed.  Zero or less means clones are kept
+	// until ClearCloneCache is called.
+	CloneCacheMaxAge time.Duration
+
+	// CloneTimeout limits the time spent cloning a repository.
+	// Zero or less means no limit.
+	CloneTimeout time.Duration
+
+	// RecurseSubmodules, if true, means submodules of cloned
+	// repositories are cloned too, so their markdown gets loaded.
+	RecurseSubmodules bool
+
+	// ContinueOnError, if true, means a file or folder that can't be
+	// loaded is skipped, rather than ending the load with an error.
+	// What was skipped, and why, is reported by Diagnostics.
+	ContinueOnError bool
+
+	// MaxFileSize, if positive, is the size in bytes of the largest file
+	// that will be loaded from a folder.  Larger files are skipped.
+	MaxFileSize int64
+
+	// MaxDepth, if positive, is how many levels of folders below the
+	// loaded folder will be loaded.  Deeper folders are skipped.
+	MaxDepth int
+
+	// MaxFiles, if positive, is the most files that will be loaded from
+	// one folder or archive.  Finding more ends the load with a *LimitError.
+	MaxFiles int
+
+	// MaxTotalBytes, if positive, is the most bytes that will be read
+	// from the files in one folder, archive or git ref.  Finding more ends
+	// the load with a *LimitError.
+	MaxTotalBytes int64
+
+	fs      *afero.Afero
+	diagLog *diagnosticLog
 }
 
 // NewFsLoader returns a file system (FS) loader with default filters.
@@ -19,12 +69,27 @@ type FsLoader struct {
 // For a "real" disk-based system, inject afero.NewOsFs().
 func NewFsLoader(fs afero.Fs) *FsLoader {
 	return &FsLoader{
-		IsAllowedFile:   IsMarkDownFile,
-		IsAllowedFolder: IsNotADotDir,
-		fs:              &afero.Afero{Fs: fs},
+		IsAllowedFile:    IsMarkDownFile,
+		IsAllowedFolder:  IsNotADotDir,
+		CloneCacheDir:    defaultCloneCacheDir(),
+		CloneCacheMaxAge: DefaultCloneCacheMaxAge,
+		CloneTimeout:     DefaultCloneTimeout,
+		MaxFileSize:      DefaultMaxFileSize,
+		MaxDepth:         DefaultMaxDepth,
+		MaxFiles:         DefaultMaxFiles,
+		MaxTotalBytes:    DefaultMaxTotalBytes,
+		fs:               &afero.Afero{Fs: fs},
+		diagLog:          &diagnosticLog{},
 	}
 }
 
+// withFs returns a copy of the loader that reads from the given file system.
+func (fsl *FsLoader) withFs(fs afero.Fs) *FsLoader {
+	c := *fsl
+	c.fs = &afero.Afero{Fs: fs}
+	return &c
+}
+
 const (
 	ReadmeFileName   = "README.md"
 	OrderingFileName = "README_ORDER.txt"
@@ -34,12 +99,39 @@ const (
 	upDir            = ".."
 )
 
-// LoadTree loads a file tree from disk, possibly after first cloning a repo from GitHub.
+// LoadTree loads a file tree from disk, possibly after first cloning a git
+// repository (see CloneAndLoadRepo).  Paths to zip and tar files are loaded with LoadArchive,
+// and paths to snapshots with LoadSnapshot.
+// An argument like ../mdrip@v1.0.1/docs, naming a path at a ref of a local
+// git repository, is loaded with LoadGitRef.
+// The StdinArg loads one markdown document from standard input.
 func (fsl *FsLoader) LoadTree(rawPath string) (*MyFolder, error) {
-	if smellsLikeGithubCloneArg(rawPath) {
+	if rawPath == StdinArg {
+		return fsl.LoadReader(os.Stdin, StdinFileName)
+	}
+	if smellsLikeGitCloneArg(rawPath) {
 		return CloneAndLoadRepo(fsl, rawPath)
 	}
-	return fsl.LoadFolder(rawPath)
+	if _, ok := cutHTTPScheme(rawPath); ok {
+		return nil, fmt.Errorf(
+			"%q isn't a known git repository; only git repositories can be loaded "+
+				"from urls, so use a .git suffix or the %s prefix", rawPath, gitPlus)
+	}
+	if repoDir, ref, p, ok := fsl.splitGitRefArg(rawPath); ok {
+		return fsl.LoadGitRef(repoDir, ref, p)
+	}
+	return fsl.loadPath(rawPath)
+}
+
+// loadPath loads a snapshot, an archive or a folder.
+func (fsl *FsLoader) loadPath(path string) (*MyFolder, error) {
+	if _, ok := SnapshotFormatOf(path); ok {
+		return fsl.LoadSnapshot(path)
+	}
+	if smellsLikeArchive(path) {
+		return fsl.LoadArchive(path)
+	}
+	return fsl.LoadFolder(path)
 }
 
 // LoadFolder loads the files at or below a path into memory, returning them
@@ -54,6 +146,9 @@ func (fsl *FsLoader) LoadTree(rawPath string) (*MyFolder, error) {
 // is just lines of text, one name per line. Ordered files appear first, with
 // the remainder in the order imposed by fs.ReadDir.
 //
+// File contents are converted to UTF-8 with "\n" line endings, and each
+// file records what was changed; see Normalize and MyFile.Normalization.
+//
 // If the path is a file, only that file is loaded.  Since LoadFolder must
 // return a folder, the folder's name is the path to that file minus the file's
 // name.  The path might be absolute (starting with the rootSlash) or relative,
@@ -67,7 +162,7 @@ func (fsl *FsLoader) LoadTree(rawPath string) (*MyFolder, error) {
 //	------------------+----------------------+--------------
 //	           foo.md |                    . | foo.md
 //	         ./foo.md |                    . | foo.md
-//	        ../foo.md |            {illegal} | {illegal}
+//	        ../foo.md |                   .. | foo.md
 //	/usr/local/foo.md |           /usr/local | foo.md
 //	       bar/foo.md |                  bar | foo.md
 //
@@ -82,22 +177,19 @@ func (fsl *FsLoader) LoadTree(rawPath string) (*MyFolder, error) {
 //	                . |                    . | {whatever}
 //	              foo |                  foo | {whatever}
 //	            ./foo |                  foo | {whatever}
-//	           ../foo |            {illegal} | {illegal}
+//	           ../foo |               ../foo | {whatever}
 //	   /usr/local/foo |       /usr/local/foo | {whatever}
 //	          bar/foo |              bar/foo | {whatever}
 //
+// If DisplayRoot is set, it's used as the name of the returned folder
+// instead of the names shown above.  A file's FullName is then no longer
+// a path on the file system, but MyFile.Load still works.
+//
 // Any error returned will be from the file system.
 func (fsl *FsLoader) LoadFolder(rawPath string) (fld *MyFolder, err error) {
 	// If rawPath is empty, cleanPath ends up with "."
 	cleanPath := filepath.Clean(rawPath)
 
-	// For now, disallow paths that start with upDir, because in the task at
-	// hand we want a clear root directory for display. Might allow this later.
-	if strings.HasPrefix(cleanPath, upDir) {
-		return nil, fmt.Errorf(
-			"specify absolute path or something at or below your working directory")
-	}
-
 	var info os.FileInfo
 	info, err = fsl.fs.Stat(cleanPath)
 	if err != nil {
@@ -111,12 +203,15 @@ func (fsl *FsLoader) LoadFolder(rawPath string) (fld *MyFolder, err error) {
 			err = fmt.Errorf("illegal folder %q; %w", info.Name(), err)
 			return
 		}
-		fld, err = fsl.loadFolder(cleanPath)
+		st := &loadState{}
+		fld, err = fsl.loadFolder(st, cleanPath, 0)
 		if err != nil {
 			return
 		}
 		if !fld.IsEmpty() {
-			fld.name = cleanPath
+			fld.name = fsl.displayName(cleanPath)
+			fld.diskPath = cleanPath
+			fld.skipped = st.skipped
 			return
 		}
 		return nil, nil
@@ -128,16 +223,47 @@ func (fsl *FsLoader) LoadFolder(rawPath string) (fld *MyFolder, err error) {
 		err = fmt.Errorf("illegal file %q; %w", info.Name(), err)
 		return
 	}
+	// Since it was asked for, it's an error if it can't be loaded,
+	// even when continuing on error.
 	dir, base := DirBase(cleanPath)
-	var c []byte
-	c, err = fsl.fs.ReadFile(cleanPath)
-	if err != nil {
+	var fi *MyFile
+	if fi, err = fsl.loadFile(cleanPath, base, info); err != nil {
 		return nil, err
 	}
-	fld = NewFolder(dir).AddFileObject(NewFile(base, c))
+	fld = NewFolder(fsl.displayName(dir)).AddFileObject(fi)
+	fld.diskPath = dir
 	return
 }
 
+// loadFile loads the file at the path, giving it the name.
+// Failures are reported as a *Diagnostic.
+func (fsl *FsLoader) loadFile(path, name string, info os.FileInfo) (*MyFile, error) {
+	if fsl.MaxFileSize > 0 && info.Size() > fsl.MaxFileSize {
+		return nil, &Diagnostic{
+			Path: path,
+			Kind: DiagFileTooLarge,
+			Err:  fmt.Errorf("%d bytes, limit is %d", info.Size(), fsl.MaxFileSize),
+		}
+	}
+	c, err := fsl.fs.ReadFile(path)
+	if err != nil {
+		return nil, newReadDiagnostic(path, err)
+	}
+	fi, err := newLoadedFile(name, c)
+	if err != nil {
+		return nil, newReadDiagnostic(path, err)
+	}
+	return fi, nil
+}
+
+// displayName returns the name to use for the root of a loaded tree.
+func (fsl *FsLoader) displayName(path string) string {
+	if fsl.DisplayRoot != "" {
+		return fsl.DisplayRoot
+	}
+	return path
+}
+
 // loadFolder loads the folder specified by the path.
 // This is the recursive part of the LoadFolder entrypoint.
 // The path must point to a folder.
@@ -159,7 +285,11 @@ func (fsl *FsLoader) LoadFolder(rawPath string) (fld *MyFolder, err error) {
 //	    doom.md
 //
 // and the argument passed in is simply "." or an empty string.
-func (fsl *FsLoader) loadFolder(path string) (*MyFolder, error) {
+//
+// The depth of the folder is its distance from the folder given
+// to LoadFolder, which has depth zero.
+func (fsl *FsLoader) loadFolder(
+	st *loadState, path string, depth int) (*MyFolder, error) {
 	var (
 		result   MyFolder
 		subFld   *MyFolder
@@ -167,16 +297,32 @@ func (fsl *FsLoader) loadFolder(path string) (*MyFolder, error) {
 	)
 	dirEntries, err := fsl.fs.ReadDir(path)
 	if err != nil {
-		return nil, fmt.Errorf(
-			"unable to read folder %q; %w", path, err)
+		return nil, newReadDiagnostic(path, err)
 	}
 	for i := range dirEntries {
 		info := dirEntries[i]
 		subPath := filepath.Join(path, info.Name())
 		if info.IsDir() {
 			if err = fsl.IsAllowedFolder(info); err == nil {
-				if subFld, err = fsl.loadFolder(subPath); err != nil {
-					return nil, err
+				if fsl.MaxDepth > 0 && depth+1 > fsl.MaxDepth {
+					if err = fsl.report(st, &Diagnostic{
+						Path: subPath,
+						Kind: DiagTooDeep,
+						Err:  fmt.Errorf("more than %d folders deep", fsl.MaxDepth),
+					}); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				if subFld, err = fsl.loadFolder(st, subPath, depth+1); err != nil {
+					var d *Diagnostic
+					if !errors.As(err, &d) {
+						return nil, err
+					}
+					if err = fsl.report(st, d); err != nil {
+						return nil, err
+					}
+					continue
 				}
 				if !subFld.IsEmpty() {
 					subFld.name = info.Name()
@@ -188,14 +334,23 @@ func (fsl *FsLoader) loadFolder(path string) (*MyFolder, error) {
 		if IsOrderingFile(info) {
 			// load it and keep it for use at end of function.
 			if ordering, err = LoadOrderFile(fsl.fs, subPath); err != nil {
-				return nil, err
+				ordering = nil
+				if err = fsl.report(st, &Diagnostic{
+					Path: subPath, Kind: DiagBadOrderingFile, Err: err}); err != nil {
+					return nil, err
+				}
 			}
 			continue
 		}
 		if err = fsl.IsAllowedFile(info); err == nil {
-			fi := NewEmptyFile(info.Name())
-			fi.content, err = fsl.fs.ReadFile(subPath)
-			if err != nil {
+			var fi *MyFile
+			if fi, err = fsl.loadFile(subPath, info.Name(), info); err != nil {
+				if err = fsl.report(st, err.(*Diagnostic)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err = fsl.count(st, subPath, int64(len(fi.content))); err != nil {
 				return nil, err
 			}
 			result.AddFileObject(fi)
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -156,10 +157,30 @@ func TestLoadFolderFromMemoryHappy(t *testing.T) {
 			pathToLoad: "/monkey",
 			errMsg:     "does not exist",
 		},
-		"noGoingUp": {
+		"goingUpNonExistent": {
 			fillFs:     makeSmallAbsFs,
 			pathToLoad: "../zzz",
-			errMsg:     "specify absolute path or something at or below your working directory",
+			errMsg:     "does not exist",
+		},
+		"goingUpFolder": {
+			fillFs: func(tt *testing.T, fs afero.Fs) {
+				assert.NoError(tt, afero.WriteFile(fs, "../shared/f01.md", md[1].C(), RW))
+				assert.NoError(tt, afero.WriteFile(fs, "../shared/aaa/f02.md", md[2].C(), RW))
+			},
+			pathToLoad: "../shared",
+			expectedFld: func() *MyFolder {
+				aaa := NewFolder("aaa").AddFileObject(md[2])
+				return NewFolder("../shared").AddFileObject(md[1]).AddFolderObject(aaa)
+			},
+		},
+		"goingUpFile": {
+			fillFs: func(tt *testing.T, fs afero.Fs) {
+				assert.NoError(tt, afero.WriteFile(fs, "../f01.md", md[1].C(), RW))
+			},
+			pathToLoad: "../f01.md",
+			expectedFld: func() *MyFolder {
+				return NewFolder("..").AddFileObject(md[1])
+			},
 		},
 		"oneFile": {
 			fillFs: func(tt *testing.T, fs afero.Fs) {
@@ -338,6 +359,41 @@ func TestLoadFolderFromMemoryHappy(t *testing.T) {
 	}
 }
 
+func TestLoadFolderWithDisplayRoot(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "../shared/f01.md", md[1].C(), RW))
+	assert.NoError(t, afero.WriteFile(fs, "../shared/aaa/f02.md", md[2].C(), RW))
+	ldr := NewFsLoader(fs)
+	ldr.DisplayRoot = "shared"
+	fld, err := ldr.LoadFolder("../shared")
+	assert.NoError(t, err)
+	assert.Equal(t, "shared", fld.Name())
+	assert.Equal(t, "shared", fld.FullName())
+	// Files can still be reloaded from disk.
+	fi := fld.Lookup("aaa/f02").(*MyFile)
+	assert.Equal(t, filepath.Join("shared", "aaa", "f02.md"), fi.FullName())
+	assert.NoError(t, afero.WriteFile(fs, "../shared/aaa/f02.md", []byte("# changed"), RW))
+	assert.NoError(t, fi.Load(ldr))
+	assert.Equal(t, "# changed", string(fi.C()))
+	// So can files in copies of the tree.
+	assert.NoError(t, afero.WriteFile(fs, "../shared/aaa/f02.md", []byte("# again"), RW))
+	for _, cp := range []*MyFolder{
+		fld.Clone(),
+		fld.Filter(func(fi *MyFile) bool { return fi.Name() == "f02.md" }),
+	} {
+		fi = cp.Lookup("aaa/f02").(*MyFile)
+		assert.NoError(t, fi.Load(ldr))
+		assert.Equal(t, "# again", string(fi.C()))
+	}
+
+	fld, err = ldr.LoadFolder("../shared/aaa/f02.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "shared", fld.Name())
+	assert.True(t, fld.HasFile("f02.md"))
+	assert.NoError(t, fld.Lessons()[0].Load(ldr))
+	assert.Equal(t, "# again", string(fld.Lessons()[0].C()))
+}
+
 const runTheUnportableLocalFileSystemDependentTests = false
 
 func TestLoadTree(t *testing.T) {
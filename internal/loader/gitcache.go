@@ -0,0 +1,328 @@
+package loader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultCloneTimeout is how long cloning a repository may take by default.
+	DefaultCloneTimeout = 5 * time.Minute
+	// DefaultCloneCacheMaxAge is how long a cached clone is kept unused by default.
+	DefaultCloneCacheMaxAge = 30 * 24 * time.Hour
+)
+
+// cloneContext returns a context that expires after the CloneTimeout.
+func (fsl *FsLoader) cloneContext() (context.Context, context.CancelFunc) {
+	if fsl.CloneTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), fsl.CloneTimeout)
+}
+
+// defaultCloneCacheDir returns the directory in which to keep clones,
+// or an empty string if the user has no cache directory.
+func defaultCloneCacheDir() string {
+	d, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(d, "mdparse", "clones")
+}
+
+// checkOut makes a local copy of the path and commit specified by the
+// repoSpec, returning the directory holding it and a function to call
+// when done with the directory.
+//
+// Only one commit is fetched (a shallow clone), and only the requested
+// path is written to disk (a sparse checkout).
+//
+// If the loader has a CloneCacheDir, the directory is kept there, keyed by
+// remote, path and commit, so a later call asking for the same thing
+// needn't fetch anything; only `git ls-remote` is run to resolve the ref.
+// Cached directories unused for CloneCacheMaxAge are then removed.
+// Otherwise, the directory is a temporary directory removed by the
+// returned function.
+func (fsl *FsLoader) checkOut(
+	ctx context.Context, rs *repoSpec) (string, func(), error) {
+	noop := func() {}
+	commit, err := resolveRef(ctx, rs)
+	if err != nil {
+		return "", noop, err
+	}
+	if fsl.CloneCacheDir == "" {
+		var tmpDir string
+		tmpDir, err = os.MkdirTemp("", "mdrip-git-")
+		if err != nil {
+			return "", noop, fmt.Errorf("unable to create tmp dir (%w)", err)
+		}
+		cleanUp := func() { _ = os.RemoveAll(tmpDir) }
+		return tmpDir, cleanUp, fsl.fetchCommit(ctx, tmpDir, rs, commit)
+	}
+	parent := filepath.Join(fsl.CloneCacheDir, cacheKey(rs, fsl.RecurseSubmodules))
+	dir := filepath.Join(parent, commit)
+	defer fsl.evictClones(dir)
+	if _, err = os.Stat(dir); err == nil {
+		slog.Info("Using cached clone", "dir", dir)
+		touch(dir)
+		return dir, noop, nil
+	}
+	if err = os.MkdirAll(parent, 0755); err != nil {
+		return "", noop, fmt.Errorf("unable to create cache dir (%w)", err)
+	}
+	// Fill a temporary directory, then rename it, so that the cache
+	// never holds a partial checkout.
+	tmpDir, err := os.MkdirTemp(parent, commit+".tmp-")
+	if err != nil {
+		return "", noop, fmt.Errorf("unable to create tmp dir (%w)", err)
+	}
+	if err = fsl.fetchCommit(ctx, tmpDir, rs, commit); err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return "", noop, err
+	}
+	if err = os.Rename(tmpDir, dir); err != nil {
+		// Maybe another process got there first.
+		_ = os.RemoveAll(tmpDir)
+		if _, err2 := os.Stat(dir); err2 != nil {
+			return "", noop, fmt.Errorf("unable to fill cache (%w)", err)
+		}
+	}
+	touch(dir)
+	return dir, noop, nil
+}
+
+// touch records that a cached checkout was used, by setting its
+// modification time, so evictClones keeps it.
+func touch(dir string) {
+	now := time.Now()
+	_ = os.Chtimes(dir, now, now)
+}
+
+// cacheKeyDirs returns the directories in the CloneCacheDir named by
+// cacheKey.  Nothing else in the CloneCacheDir is touched.
+func (fsl *FsLoader) cacheKeyDirs() []string {
+	entries, err := os.ReadDir(fsl.CloneCacheDir)
+	if err != nil {
+		return nil
+	}
+	var result []string
+	for _, e := range entries {
+		if e.IsDir() && len(e.Name()) == cacheKeyLen && isHex(e.Name()) {
+			result = append(result, filepath.Join(fsl.CloneCacheDir, e.Name()))
+		}
+	}
+	return result
+}
+
+// evictClones removes the cached checkouts, other than the one
+// given, that haven't been used for CloneCacheMaxAge.
+func (fsl *FsLoader) evictClones(keep string) {
+	if fsl.CloneCacheMaxAge <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-fsl.CloneCacheMaxAge)
+	for _, parent := range fsl.cacheKeyDirs() {
+		entries, err := os.ReadDir(parent)
+		if err != nil {
+			continue
+		}
+		left := len(entries)
+		for _, e := range entries {
+			dir := filepath.Join(parent, e.Name())
+			info, err := e.Info()
+			if err != nil || dir == keep || info.ModTime().After(cutoff) {
+				continue
+			}
+			slog.Info("Removing unused cached clone", "dir", dir)
+			if os.RemoveAll(dir) == nil {
+				left--
+			}
+		}
+		if left == 0 {
+			_ = os.Remove(parent)
+		}
+	}
+}
+
+// ClearCloneCache removes all the checkouts in the CloneCacheDir.
+func (fsl *FsLoader) ClearCloneCache() error {
+	if fsl.CloneCacheDir == "" {
+		return nil
+	}
+	for _, dir := range fsl.cacheKeyDirs() {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("unable to clear clone cache (%w)", err)
+		}
+	}
+	return nil
+}
+
+// cacheKeyLen is the length of a cacheKey.
+const cacheKeyLen = 16
+
+// cacheKey names the cache directory holding checkouts of the
+// remote and path in the repoSpec, with or without submodules.
+func cacheKey(rs *repoSpec, withSubmodules bool) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf(
+		"%s\n%s\n%t", rs.url(), rs.path, withSubmodules)))
+	return hex.EncodeToString(sum[:cacheKeyLen/2])
+}
+
+// resolveRef returns the commit named by the repoSpec's ref,
+// or the commit at the tip of the default branch if there's no ref.
+// A ref that's not a branch or tag, but looks like an abbreviated
+// commit, is found with resolveAbbreviatedCommit.
+func resolveRef(ctx context.Context, rs *repoSpec) (string, error) {
+	if isFullCommitHash(rs.ref) {
+		return rs.ref, nil
+	}
+	patterns := []string{"HEAD"}
+	if rs.ref != "" {
+		patterns = []string{rs.ref, rs.ref + "^{}"}
+	}
+	out, err := runGit(ctx, "", append([]string{"ls-remote", rs.url()}, patterns...)...)
+	if err != nil {
+		return "", err
+	}
+	refs := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		if hash, name, ok := strings.Cut(line, "\t"); ok {
+			refs[name] = hash
+		}
+	}
+	if rs.ref == "" {
+		if hash, ok := refs["HEAD"]; ok {
+			return hash, nil
+		}
+		return "", fmt.Errorf("unable to find default branch of %s", rs.url())
+	}
+	// Prefer exact matches; a peeled tag is the commit it points to.
+	for _, name := range []string{
+		"refs/heads/" + rs.ref,
+		"refs/tags/" + rs.ref + "^{}",
+		"refs/tags/" + rs.ref,
+		rs.ref,
+	} {
+		if hash, ok := refs[name]; ok {
+			return hash, nil
+		}
+	}
+	if isAbbreviatedCommitHash(rs.ref) {
+		return resolveAbbreviatedCommit(ctx, rs)
+	}
+	return "", fmt.Errorf("unable to find ref %q in %s", rs.ref, rs.url())
+}
+
+// resolveAbbreviatedCommit returns the full hash of the abbreviated
+// commit in the repoSpec's ref.  Servers don't resolve abbreviations,
+// so the history of the repository's branches and tags is fetched,
+// without trees or blobs if the server allows, into a temporary
+// repository, where git can find the commit.
+func resolveAbbreviatedCommit(ctx context.Context, rs *repoSpec) (string, error) {
+	dir, err := os.MkdirTemp("", "mdrip-git-")
+	if err != nil {
+		return "", fmt.Errorf("unable to create tmp dir (%w)", err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err = runGit(ctx, dir, "init", "--quiet", "--bare"); err != nil {
+		return "", err
+	}
+	if _, err = runGit(ctx, dir, "remote", "add", "origin", rs.url()); err != nil {
+		return "", err
+	}
+	if err = fetchFiltered(ctx, dir, "tree:0",
+		"origin", "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"); err != nil {
+		return "", err
+	}
+	out, err := runGit(ctx, dir, "rev-parse", "--verify", "--quiet", rs.ref+"^{commit}")
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("unable to find ref %q in %s", rs.ref, rs.url())
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// fetchFiltered runs `git fetch` with a partial clone filter, so that
+// fewer objects are downloaded, falling back to a plain fetch if the
+// filter is refused.
+func fetchFiltered(ctx context.Context, dir string, filter string, args ...string) error {
+	_, err := runGit(ctx, dir,
+		append([]string{"fetch", "--quiet", "--filter=" + filter}, args...)...)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	slog.Info("Fetching without a filter", "filter", filter, "err", err)
+	_, err = runGit(ctx, dir, append([]string{"fetch", "--quiet"}, args...)...)
+	return err
+}
+
+func isFullCommitHash(s string) bool {
+	return (len(s) == 40 || len(s) == 64) && isHex(s)
+}
+
+// isAbbreviatedCommitHash is true for strings that git could take as
+// a shortened commit hash.
+func isAbbreviatedCommitHash(s string) bool {
+	return len(s) >= 4 && len(s) < 64 && isHex(s)
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchCommit fills the empty directory with a shallow,
+// sparse checkout of the given commit, and maybe its submodules.
+// Only the blobs in the sparse checkout are downloaded, if the
+// server allows partial clones.
+func (fsl *FsLoader) fetchCommit(
+	ctx context.Context, dir string, rs *repoSpec, commit string) (err error) {
+	slog.Info("Cloning", "dir", dir, "url", rs.url(), "ref", rs.ref, "commit", commit)
+	if _, err = runGit(ctx, dir, "init", "--quiet"); err != nil {
+		return err
+	}
+	if _, err = runGit(ctx, dir, "remote", "add", "origin", rs.url()); err != nil {
+		return err
+	}
+	if rs.path != "" {
+		if _, err = runGit(ctx, dir, "config", "core.sparseCheckout", "true"); err != nil {
+			return err
+		}
+		info := filepath.Join(dir, dotGit, "info")
+		if err = os.MkdirAll(info, 0755); err != nil {
+			return fmt.Errorf("unable to make %s (%w)", info, err)
+		}
+		if err = os.WriteFile(
+			filepath.Join(info, "sparse-checkout"),
+			[]byte(rootSlash+filepath.ToSlash(rs.path)+"\n"), 0644); err != nil {
+			return fmt.Errorf("unable to write sparse-checkout file (%w)", err)
+		}
+	}
+	if err = fetchFiltered(ctx, dir, "blob:none", "--depth", "1", "origin", commit); err != nil {
+		return err
+	}
+	if _, err = runGit(ctx, dir, "checkout", "--quiet", "FETCH_HEAD"); err != nil {
+		return err
+	}
+	if fsl.RecurseSubmodules {
+		if _, err = runGit(ctx, dir,
+			"submodule", "update", "--quiet", "--init", "--recursive", "--depth", "1"); err != nil {
+			return err
+		}
+	}
+	slog.Info("Clone complete.")
+	return nil
+}
@@ -2,45 +2,148 @@ package loader
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log/slog"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 )
 
-const dotGit = ".git"
+const (
+	dotGit = ".git"
+	// refMarker separates a repository name from a branch, tag or commit,
+	// as in gh:monopole/mdrip@v1.0.1/data.
+	refMarker = "@"
+)
 
-// smellsLikeGithubCloneArg returns true if the argument seems
-// like it could be GitHub url or `git clone` argument.
-func smellsLikeGithubCloneArg(arg string) bool {
+// smellsLikeGitCloneArg returns true if the argument seems
+// like it could be a git repository url or `git clone` argument,
+// e.g. one of
+//
+//	gh:monopole/mdrip
+//	gl:someGroup/someRepo
+//	git@github.com:monopole/mdrip.git
+//	git@git.example.org:team/docs
+//	https://github.com/monopole/mdrip
+//	https://git.example.org/team/docs.git
+//	git+https://git.example.org/team/docs
+//	ssh://git@git.example.org:2222/team/docs.git
+//	file:///srv/git/docs.git
+//
+// An http(s) url is only taken as a repository if it's on a known git
+// host (see isGitHost) or has a ".git" suffix, since it could just as
+// well be a web page or a file.  The "git+" prefix says it's a repository.
+func smellsLikeGitCloneArg(arg string) bool {
 	arg = strings.ToLower(arg)
-	return strings.HasPrefix(arg, "gh:") ||
-		strings.HasPrefix(arg, "git@github.com:") ||
-		strings.HasPrefix(arg, "https://github.com/")
+	for _, p := range []string{
+		"gh:", "gl:", "file://", "ssh://", "git://", gitPlus,
+	} {
+		if strings.HasPrefix(arg, p) {
+			return true
+		}
+	}
+	if rest, ok := cutHTTPScheme(arg); ok {
+		host, p, _ := strings.Cut(rest, "/")
+		return isGitHost(host) || indexDotGit(p) > 0
+	}
+	// Look for the scp-like syntax, user@host:path
+	i := strings.Index(arg, ":")
+	return i > 0 && strings.Contains(arg[:i], "@") && !strings.Contains(arg[:i], "/")
+}
+
+// gitPlus marks an url as a git repository, as in git+https://host/repo.
+const gitPlus = "git+"
+
+// cutHTTPScheme returns what follows http:// or https://, if either begins the url.
+func cutHTTPScheme(url string) (string, bool) {
+	url = strings.ToLower(url)
+	for _, p := range []string{"https://", "http://"} {
+		if strings.HasPrefix(url, p) {
+			return url[len(p):], true
+		}
+	}
+	return "", false
+}
+
+// isGitHost is true for hosts (with an optional port) known to serve
+// only git repositories at their top level: the public forges, and hosts
+// named like GitHub Enterprise or self-managed GitLab, e.g. github.example.com.
+func isGitHost(host string) bool {
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	host, _, _ = strings.Cut(host, ":")
+	switch host {
+	case "github.com", "gitlab.com", "bitbucket.org", "codeberg.org":
+		return true
+	}
+	return strings.HasPrefix(host, "github.") || strings.HasPrefix(host, "gitlab.")
+}
+
+// repoSpec identifies a git repository, a commit in it,
+// and a path in that commit.
+type repoSpec struct {
+	// domain holds what's needed to reach the repository's host,
+	// e.g. "git@github.com:", "https://gitlab.com/", "file://".
+	domain string
+	// repo is the name of the repository on the host, e.g. "monopole/mdrip".
+	repo string
+	// ref is a branch, tag or commit; if empty, use the default branch.
+	ref string
+	// path is a file or folder in the repository; if empty, use everything.
+	path string
+}
+
+// parseRepoSpec parses a clone argument, e.g. gh:monopole/mdrip@v1.0.1/data.
+func parseRepoSpec(arg string) (*repoSpec, error) {
+	d, n := splitDomainAndRemainder(arg)
+	if n == "" {
+		return nil, fmt.Errorf("no repository specified in %q", arg)
+	}
+	r, p, err := splitRepoAndPath(n)
+	if err != nil {
+		return nil, err
+	}
+	r, ref := splitRepoAndRef(r)
+	return &repoSpec{domain: d, repo: r, ref: ref, path: p}, nil
+}
+
+// url is the argument to use with `git clone`.
+func (rs *repoSpec) url() string {
+	return rs.domain + rs.repo + dotGit
+}
+
+// displayName is the name to give the folder holding the repository.
+func (rs *repoSpec) displayName() string {
+	if rs.ref == "" {
+		return rs.domain + rs.repo
+	}
+	return rs.domain + rs.repo + refMarker + rs.ref
 }
 
 // CloneAndLoadRepo clones a repo locally and loads it.
+// Only the commit and path asked for are fetched from the repository;
+// see FsLoader.CloneCacheDir.
+//
 // The FsLoader should be injected with a real file system,
 // since the git command line used here clones to real disk.
 func CloneAndLoadRepo(fsl *FsLoader, arg string) (*MyFolder, error) {
-	d, n := splitDomainAndRemainder(arg)
-	r, p, err := splitRepoAndPath(n)
+	rs, err := parseRepoSpec(arg)
 	if err != nil {
 		return nil, err
 	}
-	var (
-		tmpDir string
-		fld    *MyFolder
-	)
-	tmpDir, err = cloneRepo(d, r)
-	defer os.RemoveAll(tmpDir)
+	var fld *MyFolder
+	ctx, cancel := fsl.cloneContext()
+	defer cancel()
+	dir, cleanUp, err := fsl.checkOut(ctx, rs)
+	defer cleanUp()
 	if err != nil {
 		return nil, err
 	}
-	fld, err = fsl.LoadFolder(filepath.Join(tmpDir, p))
-	if err != nil {
+	p := rs.path
+	fld, err = fsl.loadPath(filepath.Join(dir, p))
+	if err != nil || fld == nil {
 		return nil, err
 	}
 	if fld.NumFiles() == 1 && fld.NumFolders() == 0 {
@@ -48,23 +151,39 @@ func CloneAndLoadRepo(fsl *FsLoader, arg string) (*MyFolder, error) {
 		p = strings.TrimSuffix(p, rootSlash)
 	}
 	if p == "" {
-		fld.name = d + r
+		fld.name = rs.displayName()
 		return fld, nil
 	}
-	fld.name = d + r + rootSlash + p
+	fld.name = rs.displayName() + rootSlash + p
 	return fld, nil
 }
 
 // splitRepoAndPath parses strings like monopole/mdrip.git/somepath or
 // monopole/mdrip, splitting the repository name
 // and the path inside the repository.
+//
+// Without the ".git" suffix, the repository name is assumed to have the
+// form ORGANIZATION/REPONAME.  Use the suffix to specify names with more
+// or fewer parts, e.g. GitLab subgroups or paths to local repositories;
+// file:///srv/git/docs/guide is an error, since its ORGANIZATION is empty.
+//
+// A ref (e.g. monopole/mdrip@v1.0.1 or monopole/mdrip.git@v1.0.1)
+// is kept with the repository name; see splitRepoAndRef.
 func splitRepoAndPath(n string) (string, string, error) {
-	if i := strings.Index(n, dotGit); i > 0 {
+	if i := indexDotGit(n); i > 0 {
 		r := n[:i]
 		after := n[i+len(dotGit):]
+		if strings.HasPrefix(after, refMarker) {
+			j := strings.Index(after, "/")
+			if j < 0 {
+				return r + after, "", nil
+			}
+			r += after[:j]
+			after = after[j:]
+		}
 		if len(after) > 1 {
 			if !strings.HasPrefix(after, "/") {
-				return "", "", fmt.Errorf("no path separator in github spec")
+				return "", "", fmt.Errorf("no path separator in repository spec")
 			}
 			return r, after[1:], nil
 		}
@@ -73,7 +192,7 @@ func splitRepoAndPath(n string) (string, string, error) {
 	i := strings.Index(n, "/")
 	if i < 1 {
 		// expect ORGANIZATION/REPONAME
-		return "", "", fmt.Errorf("no org/repo separator in github spec")
+		return "", "", fmt.Errorf("no org/repo separator in repository spec")
 	}
 	// Now look for a second path separator
 	j := strings.Index(n[i+1:], "/")
@@ -85,41 +204,152 @@ func splitRepoAndPath(n string) (string, string, error) {
 	return n[:j], n[j+1:], nil
 }
 
+// indexDotGit returns the index of the first ".git" that ends
+// a repository name, or -1.  The ".git" in ".github" doesn't count.
+func indexDotGit(n string) int {
+	for i := 0; i < len(n); {
+		k := strings.Index(n[i:], dotGit)
+		if k < 0 {
+			return -1
+		}
+		k += i
+		end := k + len(dotGit)
+		if end == len(n) || n[end] == '/' || strings.HasPrefix(n[end:], refMarker) {
+			return k
+		}
+		i = end
+	}
+	return -1
+}
+
+// splitRepoAndRef splits monopole/mdrip@v1.0.1 into
+// the repository name and the ref.
+//
+// The ref ends at the first "/", since the path follows it, so refs
+// holding a "/" can't be named; in monopole/mdrip@release/v1/docs
+// the ref is "release" and the path is "v1/docs".
+func splitRepoAndRef(r string) (string, string) {
+	i := strings.LastIndex(r, "/") + 1
+	if j := strings.Index(r[i:], refMarker); j > 0 {
+		j += i
+		return r[:j], r[j+len(refMarker):]
+	}
+	return r, ""
+}
+
+// splitDomainAndRemainder splits a clone argument into the part
+// that identifies the host and the remainder.
 func splitDomainAndRemainder(raw string) (string, string) {
 	n := strings.ToLower(raw)
-	{
-		p := "gh:"
-		if n[:len(p)] == p {
-			return "git@github.com:", raw[len(p):]
+	if strings.HasPrefix(n, gitPlus) {
+		raw, n = raw[len(gitPlus):], n[len(gitPlus):]
+	}
+	for _, x := range []struct{ prefix, domain string }{
+		{"gh:", "git@github.com:"},
+		{"gl:", "git@gitlab.com:"},
+		{"file://", "file://"},
+	} {
+		if strings.HasPrefix(n, x.prefix) {
+			return x.domain, raw[len(x.prefix):]
 		}
 	}
-	// try any domain-like thing
-	for _, p := range []string{".com/", ".com:"} {
-		if i := strings.Index(n, p); i > 0 {
-			return n[:i+len(p)], raw[i+len(p):]
+	if i := strings.Index(n, "://"); i > 0 {
+		// scheme://host/, scheme://host:port/ or scheme://host:
+		i += len("://")
+		j := strings.IndexAny(n[i:], "/:")
+		if j < 0 {
+			return raw, ""
+		}
+		j += i
+		if n[j] == ':' {
+			if k := strings.Index(n[j:], "/"); k > 1 && isDigits(n[j+1:j+k]) {
+				j += k
+			}
 		}
+		return n[:j+1], raw[j+1:]
+	}
+	// Try the scp-like syntax, user@host:
+	if i := strings.Index(n, ":"); i > 0 && strings.Contains(n[:i], "@") {
+		return n[:i+1], raw[i+1:]
 	}
 	// err?
 	return raw, ""
 }
 
-func cloneRepo(domain, repoName string) (string, error) {
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// GitError reports a failed git command.
+type GitError struct {
+	// Args are the arguments given to git.
+	Args []string
+	// Dir is the directory git ran in.
+	Dir string
+	// Stderr is what git wrote to stderr, with surrounding space trimmed.
+	Stderr string
+	// Err is why the command failed, e.g. an *exec.ExitError,
+	// or context.DeadlineExceeded if the command took too long.
+	Err error
+}
+
+func (e *GitError) Error() string {
+	msg := fmt.Sprintf("git %s failure (%v)", e.Args[0], e.Err)
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns git's exit code, or -1 if git didn't exit normally.
+func (e *GitError) ExitCode() int {
+	var exitErr *exec.ExitError
+	if errors.As(e.Err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// runGit runs git with the given arguments in the given directory,
+// returning what git wrote to stdout.  Failures are reported as a *GitError.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	return runGitWithInput(ctx, dir, nil, args...)
+}
+
+// runGitWithInput is runGit with the given bytes fed to git's stdin.
+func runGitWithInput(
+	ctx context.Context, dir string, in []byte, args ...string) (string, error) {
 	gitPath, err := exec.LookPath("git")
 	if err != nil {
 		return "", fmt.Errorf("maybe no git program? (%w)", err)
 	}
-	tmpDir, err := os.MkdirTemp("", "mdrip-git-")
-	if err != nil {
-		return "", fmt.Errorf("unable to create tmp dir (%w)", err)
+	cmd := exec.CommandContext(ctx, gitPath, args...)
+	cmd.Dir = dir
+	if in != nil {
+		cmd.Stdin = bytes.NewReader(in)
 	}
-	slog.Info("Cloning", "tmpDir", tmpDir, "domain", domain, "repoName", repoName)
-	cmd := exec.Command(
-		gitPath, "clone", domain+repoName+dotGit, tmpDir)
-	var out bytes.Buffer
+	var out, stdErr bytes.Buffer
 	cmd.Stdout = &out
+	cmd.Stderr = &stdErr
 	if err = cmd.Run(); err != nil {
-		return "", fmt.Errorf("git clone failure (%w)", err)
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return "", &GitError{
+			Args:   args,
+			Dir:    dir,
+			Stderr: strings.TrimSpace(stdErr.String()),
+			Err:    err,
+		}
 	}
-	slog.Info("Clone complete.")
-	return tmpDir, nil
+	return out.String(), nil
 }
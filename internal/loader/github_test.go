@@ -1,8 +1,16 @@
 package loader
 
 import (
+	"context"
 	"fmt"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSplitDomainAndRepo(t *testing.T) {
@@ -35,6 +43,10 @@ func TestSplitDomainAndRepo(t *testing.T) {
 			spec:   "https://github.tesla.com/REPO",
 			domain: "https://github.tesla.com/",
 		},
+		{
+			spec:   "git+https://git.example.org/REPO",
+			domain: "https://git.example.org/",
+		},
 	} {
 		t.Run(tc.spec, func(t *testing.T) {
 			d, r := splitDomainAndRemainder(tc.spec)
@@ -48,6 +60,33 @@ func TestSplitDomainAndRepo(t *testing.T) {
 	}
 }
 
+func TestSmellsLikeGitCloneArg(t *testing.T) {
+	for arg, want := range map[string]bool{
+		"gh:monopole/mdrip":                          true,
+		"git@git.example.org:team/docs":              true,
+		"https://github.com/monopole/mdrip":          true,
+		"https://GitLab.com/group/sub/repo.git/docs": true,
+		"https://github.example.com:8443/team/docs":  true,
+		"https://git.example.org/team/docs.git@v1":   true,
+		"git+https://git.example.org/team/docs":      true,
+		"ssh://git@git.example.org:2222/team/docs":   true,
+		"file:///srv/git/docs.git":                   true,
+		"https://example.com/doc.md":                 false,
+		"https://example.com/docs.tar.gz":            false,
+		"http://raw.githubusercontent.com/a/b/c.md":  false,
+		"https://example.com/.gitignore":             false,
+		"docs/README.md":                             false,
+		"../mdrip@v1.0.1/docs":                       false,
+	} {
+		assert.Equal(t, want, smellsLikeGitCloneArg(arg), arg)
+	}
+}
+
+func TestLoadTreeWebPage(t *testing.T) {
+	_, err := NewFsLoader(afero.NewMemMapFs()).LoadTree("https://example.com/doc.md")
+	assert.ErrorContains(t, err, "isn't a known git repository")
+}
+
 func TestSplitRepoAndPath(t *testing.T) {
 	const repoName = "monopole/mdrip"
 	for _, pathName := range []string{
@@ -88,3 +127,322 @@ func makeTheTestArgument(repoName string, pathName string, tstFmt string) string
 	}
 	return repoSpec + rootSlash + pathName
 }
+
+// makeBareRepo makes a bare repository holding two commits.
+// The first is tagged v1, and the second is on branch "main".
+// A branch named "dev" has a third commit.
+func makeBareRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git program")
+	}
+	work := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{
+			"-c", "user.name=tester", "-c", "user.email=tester@example.com",
+			"-c", "init.defaultBranch=main", "-c", "commit.gpgsign=false",
+		}, args...)...)
+		cmd.Dir = work
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(path, content string) {
+		t.Helper()
+		p := filepath.Join(work, path)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "--quiet")
+	write("README.md", "# v1 readme")
+	write("docs/a.md", "# v1 a")
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "one")
+	git("tag", "v1")
+	write("docs/a.md", "# v2 a")
+	write("docs/b.md", "# v2 b")
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "two")
+	git("checkout", "--quiet", "-b", "dev")
+	write("docs/c.md", "# dev c")
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "three")
+	git("checkout", "--quiet", "main")
+	bare := filepath.Join(t.TempDir(), "docs.git")
+	git("clone", "--quiet", "--bare", work, bare)
+	return bare
+}
+
+func TestCloneAndLoadLocalBareRepo(t *testing.T) {
+	bare := makeBareRepo(t)
+	type testC struct {
+		suffix   string
+		topName  string
+		expected func() *MyFolder
+	}
+	for n, tc := range map[string]testC{
+		"defaultBranch": {
+			topName: "file://" + bare[:len(bare)-len(dotGit)],
+			expected: func() *MyFolder {
+				docs := NewFolder("docs").
+					AddFileObject(NewFile("a.md", []byte("# v2 a"))).
+					AddFileObject(NewFile("b.md", []byte("# v2 b")))
+				return NewFolder("").
+					AddFileObject(NewFile("README.md", []byte("# v1 readme"))).
+					AddFolderObject(docs)
+			},
+		},
+		"tagAndPath": {
+			suffix:  "@v1/docs",
+			topName: "file://" + bare[:len(bare)-len(dotGit)] + "@v1/docs",
+			expected: func() *MyFolder {
+				return NewFolder("").AddFileObject(NewFile("a.md", []byte("# v1 a")))
+			},
+		},
+		"branchAndFile": {
+			suffix:  "@dev/docs/c.md",
+			topName: "file://" + bare[:len(bare)-len(dotGit)] + "@dev/docs",
+			expected: func() *MyFolder {
+				return NewFolder("").AddFileObject(NewFile("c.md", []byte("# dev c")))
+			},
+		},
+	} {
+		t.Run(n, func(t *testing.T) {
+			fld, err := newGitTestLoader(t).LoadTree("file://" + bare + tc.suffix)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tc.topName, fld.Name())
+			fld.name = ""
+			assert.True(t, tc.expected().Equals(fld))
+		})
+	}
+}
+
+func TestCloneAndLoadUnknownRef(t *testing.T) {
+	bare := makeBareRepo(t)
+	_, err := newGitTestLoader(t).LoadTree("file://" + bare + "@noSuchRef")
+	assert.Error(t, err)
+}
+
+// newGitTestLoader returns a loader that caches clones in a test directory.
+func newGitTestLoader(t *testing.T) *FsLoader {
+	fsl := NewFsLoader(afero.NewOsFs())
+	fsl.CloneCacheDir = t.TempDir()
+	return fsl
+}
+
+func TestCloneCache(t *testing.T) {
+	bare := makeBareRepo(t)
+	fsl := newGitTestLoader(t)
+	arg := "file://" + bare + "@v1/docs"
+	rs, err := parseRepoSpec(arg)
+	assert.NoError(t, err)
+	commit, err := resolveRef(context.Background(), rs)
+	assert.NoError(t, err)
+	dir := filepath.Join(fsl.CloneCacheDir, cacheKey(rs, false), commit)
+
+	fld1, err := fsl.LoadTree(arg)
+	assert.NoError(t, err)
+	// The checkout is sparse.
+	assert.FileExists(t, filepath.Join(dir, "docs", "a.md"))
+	assert.NoFileExists(t, filepath.Join(dir, "README.md"))
+	// The clone is shallow.
+	out, err := runGit(context.Background(), dir, "rev-list", "--count", "HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n", out)
+
+	// Changing the cached checkout shows that it's reused.
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "z.md"), []byte("# z"), 0644))
+	fld2, err := fsl.LoadTree(arg)
+	assert.NoError(t, err)
+	assert.Equal(t, fld1.NumFiles()+1, fld2.NumFiles())
+	entries, err := os.ReadDir(filepath.Dir(dir))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestCloneWithoutCache(t *testing.T) {
+	bare := makeBareRepo(t)
+	fsl := NewFsLoader(afero.NewOsFs())
+	fsl.CloneCacheDir = ""
+	fld, err := fsl.LoadTree("file://" + bare + "@v1/docs")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fld.NumFiles())
+}
+
+func TestResolveRef(t *testing.T) {
+	bare := makeBareRepo(t)
+	tip, err := runGit(context.Background(), bare, "rev-parse", "main")
+	assert.NoError(t, err)
+	v1, err := runGit(context.Background(), bare, "rev-parse", "v1^{commit}")
+	assert.NoError(t, err)
+	for ref, want := range map[string]string{
+		"":             tip[:len(tip)-1],
+		"main":         tip[:len(tip)-1],
+		"v1":           v1[:len(v1)-1],
+		v1[:len(v1)-1]: v1[:len(v1)-1],
+	} {
+		got, err := resolveRef(context.Background(), &repoSpec{domain: "file://", repo: bare[:len(bare)-len(dotGit)], ref: ref})
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, "ref %q", ref)
+	}
+	for _, ref := range []string{v1[:7], v1[:12], tip[:7]} {
+		got, err := resolveRef(context.Background(), &repoSpec{domain: "file://", repo: bare[:len(bare)-len(dotGit)], ref: ref})
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(got, ref), "ref %q", ref)
+		assert.True(t, isFullCommitHash(got), "ref %q", ref)
+	}
+	_, err = resolveRef(context.Background(), &repoSpec{domain: "file://", repo: bare[:len(bare)-len(dotGit)], ref: "abcdef1"})
+	assert.Error(t, err)
+}
+
+func TestCloneAbbreviatedCommit(t *testing.T) {
+	bare := makeBareRepo(t)
+	v1, err := runGit(context.Background(), bare, "rev-parse", "v1^{commit}")
+	assert.NoError(t, err)
+	fld, err := newGitTestLoader(t).LoadTree("file://" + bare + "@" + v1[:8] + "/docs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, NewFolder(fld.Name()).
+		AddFileObject(NewFile("a.md", []byte("# v1 a"))).Equals(fld))
+}
+
+func TestCloneIsPartial(t *testing.T) {
+	bare := makeBareRepo(t)
+	_, err := runGit(context.Background(), bare, "config", "uploadpack.allowFilter", "true")
+	assert.NoError(t, err)
+	fsl := newGitTestLoader(t)
+	arg := "file://" + bare + "/docs"
+	_, err = fsl.LoadTree(arg)
+	assert.NoError(t, err)
+	rs, err := parseRepoSpec(arg)
+	assert.NoError(t, err)
+	commit, err := resolveRef(context.Background(), rs)
+	assert.NoError(t, err)
+	dir := filepath.Join(fsl.CloneCacheDir, cacheKey(rs, false), commit)
+	// The README's blob, outside the sparse checkout, wasn't fetched.
+	out, err := runGit(context.Background(), dir,
+		"rev-list", "--objects", "--missing=print", "HEAD")
+	assert.NoError(t, err)
+	readme, err := runGit(context.Background(), bare, "rev-parse", "HEAD:README.md")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "?"+strings.TrimSpace(readme))
+}
+
+func TestCloneCacheEviction(t *testing.T) {
+	bare := makeBareRepo(t)
+	fsl := newGitTestLoader(t)
+	fsl.CloneCacheMaxAge = time.Hour
+	_, err := fsl.LoadTree("file://" + bare + "@v1")
+	assert.NoError(t, err)
+	_, err = fsl.LoadTree("file://" + bare + "@dev")
+	assert.NoError(t, err)
+	key, err := os.ReadDir(fsl.CloneCacheDir)
+	assert.NoError(t, err)
+	if !assert.Len(t, key, 1) {
+		return
+	}
+	parent := filepath.Join(fsl.CloneCacheDir, key[0].Name())
+	commits, err := os.ReadDir(parent)
+	assert.NoError(t, err)
+	assert.Len(t, commits, 2)
+
+	// Age the v1 checkout; loading dev again removes it.
+	v1, err := resolveRef(context.Background(), &repoSpec{
+		domain: "file://", repo: bare[:len(bare)-len(dotGit)], ref: "v1"})
+	assert.NoError(t, err)
+	old := time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(parent, v1), old, old))
+	_, err = fsl.LoadTree("file://" + bare + "@dev")
+	assert.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(parent, v1))
+	commits, err = os.ReadDir(parent)
+	assert.NoError(t, err)
+	assert.Len(t, commits, 1)
+
+	// Clearing removes the rest, but nothing else.
+	other := filepath.Join(fsl.CloneCacheDir, "other")
+	assert.NoError(t, os.Mkdir(other, 0755))
+	assert.NoError(t, fsl.ClearCloneCache())
+	assert.NoDirExists(t, parent)
+	assert.DirExists(t, other)
+}
+
+func TestCloneReportsGitErrors(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git program")
+	}
+	_, err := newGitTestLoader(t).LoadTree("file://" + t.TempDir() + "/noSuchRepo.git")
+	var gitErr *GitError
+	if !assert.ErrorAs(t, err, &gitErr) {
+		return
+	}
+	assert.Equal(t, "ls-remote", gitErr.Args[0])
+	assert.Equal(t, 128, gitErr.ExitCode())
+	assert.Contains(t, gitErr.Stderr, "noSuchRepo.git")
+	assert.Contains(t, err.Error(), gitErr.Stderr)
+}
+
+func TestCloneTimeout(t *testing.T) {
+	bare := makeBareRepo(t)
+	fsl := newGitTestLoader(t)
+	fsl.CloneTimeout = time.Nanosecond
+	_, err := fsl.LoadTree("file://" + bare)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCloneWithSubmodules(t *testing.T) {
+	sub := makeBareRepo(t)
+	// Let git use local paths as submodule urls.
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+	work := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"submodule", "--quiet", "add", "file://" + sub, "guide/sub"},
+		{"commit", "--quiet", "-m", "add submodule"},
+	} {
+		_, err := runGit(context.Background(), work, append([]string{
+			"-c", "user.name=tester", "-c", "user.email=tester@example.com",
+			"-c", "commit.gpgsign=false"}, args...)...)
+		assert.NoError(t, err)
+	}
+	main := filepath.Join(t.TempDir(), "main.git")
+	_, err := runGit(context.Background(), "", "clone", "--quiet", "--bare", work, main)
+	assert.NoError(t, err)
+
+	fsl := newGitTestLoader(t)
+	fld, err := fsl.LoadTree("file://" + main + "/guide")
+	assert.NoError(t, err)
+	assert.Nil(t, fld)
+
+	fsl.RecurseSubmodules = true
+	fld, err = fsl.LoadTree("file://" + main + "/guide")
+	assert.NoError(t, err)
+	if assert.NotNil(t, fld) {
+		assert.Equal(t, 1, fld.NumFolders())
+		assert.Equal(t, "sub", fld.dirs[0].Name())
+		assert.True(t, fld.dirs[0].HasFile("README.md"))
+	}
+}
+
+func TestParseRepoSpecAmbiguities(t *testing.T) {
+	// Without ".git" the repository is taken to be ORG/REPO.
+	_, err := parseRepoSpec("file:///srv/git/docs/guide")
+	assert.Error(t, err)
+	rs, err := parseRepoSpec("file:///srv/git/docs.git/guide")
+	assert.NoError(t, err)
+	assert.Equal(t, &repoSpec{domain: "file://", repo: "/srv/git/docs", path: "guide"}, rs)
+	// A ref ends at the first "/".
+	rs, err = parseRepoSpec("gh:monopole/mdrip@release/v1/docs")
+	assert.NoError(t, err)
+	assert.Equal(t, "release", rs.ref)
+	assert.Equal(t, "v1/docs", rs.path)
+}
@@ -0,0 +1,199 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/spf13/afero"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadGitRef loads the files at or below a path, as they are in the given
+// ref (branch, tag or commit) of the git repository in repoDir.
+//
+// The repository's working tree, if any, is neither read nor changed;
+// files are read with git plumbing commands into an in-memory file system,
+// which is then loaded with LoadFolder.  The returned folder is named
+// just as LoadFolder would name it, and the path has the same meaning,
+// except that it's relative to the top of the repository.
+//
+// If the files to be loaded hold more than MaxTotalBytes, nothing is read
+// and the function returns a *LimitError.
+func (fsl *FsLoader) LoadGitRef(repoDir, ref, path string) (*MyFolder, error) {
+	out, err := runGit(context.Background(), repoDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unable to find commit %q in %s; %w", ref, repoDir, err)
+	}
+	commit := strings.TrimSpace(out)
+	args := []string{"ls-tree", "-r", "-z", "--long", "--full-tree", commit}
+	cleanPath := filepath.Clean(path)
+	if cleanPath != currentDir {
+		args = append(args, "--", filepath.ToSlash(cleanPath))
+	}
+	if out, err = runGit(context.Background(), repoDir, args...); err != nil {
+		return nil, err
+	}
+	var (
+		names []string
+		oids  []string
+		total int64
+	)
+	for _, entry := range strings.Split(out, "\x00") {
+		var (
+			name string
+			blob *blobInfo
+		)
+		if name, blob = parseTreeEntry(entry); blob == nil {
+			continue
+		}
+		if fsl.isAllowedBlob(cleanPath, name, blob) {
+			names = append(names, name)
+			oids = append(oids, blob.oid)
+			total += blob.size
+		}
+	}
+	if err = fsl.checkTotalBytes(repoDir+refMarker+ref, total); err != nil {
+		return nil, err
+	}
+	memFs := afero.NewMemMapFs()
+	if len(oids) > 0 {
+		if out, err = runGitWithInput(
+			context.Background(), repoDir, []byte(strings.Join(oids, "\n")+"\n"),
+			"cat-file", "--batch"); err != nil {
+			return nil, err
+		}
+		if err = writeBlobs(memFs, names, []byte(out)); err != nil {
+			return nil, err
+		}
+	}
+	return fsl.withFs(memFs).LoadFolder(cleanPath)
+}
+
+// splitGitRefArg splits a LoadTree argument of the form repoDir@ref/path,
+// e.g. ../mdrip@v1.0.1/docs, naming a path at a ref of a local git
+// repository.  As with clone arguments, the ref can't hold a slash.
+// It returns false if the argument names something on the loader's
+// file system, or if no prefix ending at an "@" is a git repository.
+func (fsl *FsLoader) splitGitRefArg(arg string) (repoDir, ref, path string, ok bool) {
+	if _, err := fsl.fs.Stat(arg); err == nil {
+		return "", "", "", false
+	}
+	for i := 0; i < len(arg); i++ {
+		j := strings.Index(arg[i:], refMarker)
+		if j < 0 {
+			break
+		}
+		i += j
+		repoDir = arg[:i]
+		ref, path, _ = strings.Cut(arg[i+len(refMarker):], "/")
+		if repoDir != "" && ref != "" && isGitRepo(repoDir) {
+			return repoDir, ref, path, true
+		}
+	}
+	return "", "", "", false
+}
+
+// isGitRepo is true if the directory on disk holds a git
+// repository, either with a working tree or bare.
+func isGitRepo(dir string) bool {
+	for _, p := range []string{dotGit, "HEAD"} {
+		if _, err := os.Stat(filepath.Join(dir, p)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowedBlob is true if the blob, and the folders holding it below
+// the path being loaded, would make it through the loader's filters.
+// As with LoadFolder, the path itself, and the folders above it,
+// aren't filtered.
+func (fsl *FsLoader) isAllowedBlob(path, name string, blob *blobInfo) bool {
+	if path != currentDir {
+		p := filepath.ToSlash(path)
+		if name == p {
+			// The path is the file; all its folders are above it.
+			return IsOrderingFile(blob) || fsl.IsAllowedFile(blob) == nil
+		}
+		name = strings.TrimPrefix(name, p+"/")
+	}
+	dirs := strings.Split(name, "/")
+	for _, d := range dirs[:len(dirs)-1] {
+		if fsl.IsAllowedFolder(&blobInfo{name: d, mode: fs.ModeDir}) != nil {
+			return false
+		}
+	}
+	return IsOrderingFile(blob) || fsl.IsAllowedFile(blob) == nil
+}
+
+// parseTreeEntry parses one entry from `git ls-tree -z --long`, e.g.
+//
+//	100644 blob 0b9b2fb5f4a1dc1d3b9bbc2d9ff58b6a3a4cb49c     123\tdocs/a.md
+//
+// returning nil info if the entry isn't a regular file.
+func parseTreeEntry(entry string) (string, *blobInfo) {
+	meta, name, ok := strings.Cut(entry, "\t")
+	if !ok {
+		return "", nil
+	}
+	fields := strings.Fields(meta)
+	if len(fields) != 4 || fields[1] != "blob" {
+		// Skip submodules, and anything unexpected.
+		return "", nil
+	}
+	if fields[0] != "100644" && fields[0] != "100755" {
+		// Skip symbolic links.
+		return "", nil
+	}
+	size, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return "", nil
+	}
+	return name, &blobInfo{name: filepath.Base(name), size: size, oid: fields[2]}
+}
+
+// writeBlobs writes the output of `git cat-file --batch`
+// to the file system, using the given file names in order.
+func writeBlobs(fs afero.Fs, names []string, out []byte) error {
+	for _, name := range names {
+		header, rest, ok := bytes.Cut(out, []byte("\n"))
+		if !ok {
+			return fmt.Errorf("truncated cat-file output at %q", name)
+		}
+		fields := strings.Fields(string(header))
+		if len(fields) != 3 {
+			return fmt.Errorf("unexpected cat-file header %q for %q", header, name)
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil || size+1 > len(rest) {
+			return fmt.Errorf("bad cat-file size %q for %q", fields[2], name)
+		}
+		if err = afero.WriteFile(fs, filepath.FromSlash(name), rest[:size], 0644); err != nil {
+			return err
+		}
+		out = rest[size+1:]
+	}
+	return nil
+}
+
+// blobInfo is an os.FileInfo for a file or folder in a git tree.
+type blobInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+	oid  string
+}
+
+var _ os.FileInfo = &blobInfo{}
+
+func (b *blobInfo) Name() string       { return b.name }
+func (b *blobInfo) Size() int64        { return b.size }
+func (b *blobInfo) Mode() fs.FileMode  { return b.mode }
+func (b *blobInfo) ModTime() time.Time { return time.Time{} }
+func (b *blobInfo) IsDir() bool        { return b.mode.IsDir() }
+func (b *blobInfo) Sys() any           { return nil }
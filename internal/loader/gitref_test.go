@@ -0,0 +1,191 @@
+package loader
+
+import (
+	"context"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadGitRef(t *testing.T) {
+	bare := makeBareRepo(t)
+	type testC struct {
+		ref, path string
+		expected  func() *MyFolder
+		errMsg    string
+	}
+	for n, tc := range map[string]testC{
+		"everythingAtTag": {
+			ref: "v1",
+			expected: func() *MyFolder {
+				return NewFolder(".").
+					AddFileObject(NewFile("README.md", []byte("# v1 readme"))).
+					AddFolderObject(NewFolder("docs").AddFileObject(NewFile("a.md", []byte("# v1 a"))))
+			},
+		},
+		"folderOnBranch": {
+			ref:  "dev",
+			path: "docs",
+			expected: func() *MyFolder {
+				return NewFolder("docs").
+					AddFileObject(NewFile("a.md", []byte("# v2 a"))).
+					AddFileObject(NewFile("b.md", []byte("# v2 b"))).
+					AddFileObject(NewFile("c.md", []byte("# dev c")))
+			},
+		},
+		"oneFile": {
+			ref:  "main",
+			path: "docs/b.md",
+			expected: func() *MyFolder {
+				return NewFolder("docs").AddFileObject(NewFile("b.md", []byte("# v2 b")))
+			},
+		},
+		"noSuchPath": {
+			ref:    "v1",
+			path:   "docs/b.md",
+			errMsg: "does not exist",
+		},
+		"noSuchRef": {
+			ref:    "v2",
+			errMsg: "unable to find commit",
+		},
+	} {
+		t.Run(n, func(t *testing.T) {
+			fld, err := NewFsLoader(afero.NewMemMapFs()).LoadGitRef(bare, tc.ref, tc.path)
+			if tc.errMsg != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			if !assert.True(t, tc.expected().Equals(fld)) {
+				fld.Accept(NewVisitorDump())
+			}
+		})
+	}
+}
+
+func TestLoadGitRefLimit(t *testing.T) {
+	ldr := NewFsLoader(afero.NewMemMapFs())
+	ldr.MaxTotalBytes = 10
+	_, err := ldr.LoadGitRef(makeBareRepo(t), "v1", "")
+	var limitErr *LimitError
+	assert.ErrorAs(t, err, &limitErr)
+}
+
+func TestLoadGitRefLeavesWorkingTreeAlone(t *testing.T) {
+	bare := makeBareRepo(t)
+	work := filepath.Join(t.TempDir(), "work")
+	_, err := runGit(context.Background(), "", "clone", "--quiet", bare, work)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(work, "docs", "a.md"), []byte("# edited"), 0644))
+
+	fld, err := NewFsLoader(afero.NewOsFs()).LoadGitRef(work, "v1", "docs")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("# v1 a"), fld.files[0].C())
+	c, err := os.ReadFile(filepath.Join(work, "docs", "a.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# edited", string(c))
+	out, err := runGit(context.Background(), work, "rev-parse", "--abbrev-ref", "HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, "main\n", out)
+}
+
+func TestWorkspaceGitRoots(t *testing.T) {
+	bare := makeBareRepo(t)
+	ldr := NewFsLoader(afero.NewOsFs())
+	ldr.CloneCacheDir = t.TempDir()
+	ws := NewWorkspace(ldr)
+
+	docs, err := ws.AddGitRef(bare, "v1", "docs")
+	assert.NoError(t, err)
+	assert.Equal(t, "docs", docs.Prefix)
+	all, err := ws.AddGitRef(bare, "v1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "docs", all.Prefix)
+	assert.Len(t, ws.Roots(), 1)
+	dev, err := ws.AddGitRef(bare, "dev", "docs/c.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "docs-2", dev.Prefix)
+
+	cloned, err := ws.Add("file://" + bare + "@v1/docs")
+	assert.NoError(t, err)
+	assert.Equal(t, OriginGit, cloned.Origin.Kind)
+	assert.Equal(t, "docs-3", cloned.Prefix)
+	again, err := ws.Add("file://" + bare + "@v1/docs/a.md")
+	assert.NoError(t, err)
+	assert.Same(t, cloned, again)
+	assert.Equal(t, "docs-3/a.md", ws.Route(cloned.Folder.files[0]))
+}
+
+func TestLoadGitRefUnderDotFolder(t *testing.T) {
+	bare := makeBareRepo(t)
+	work := filepath.Join(t.TempDir(), "work")
+	_, err := runGit(context.Background(), "", "clone", "--quiet", bare, work)
+	assert.NoError(t, err)
+	for _, p := range []string{".github/docs/x.md", ".github/docs/.hidden/y.md"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(work, filepath.Dir(p)), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(work, p), []byte("# "+p), 0644))
+	}
+	_, err = runGit(context.Background(), work, "add", "-A")
+	assert.NoError(t, err)
+	_, err = runGit(context.Background(), work,
+		"-c", "user.name=tester", "-c", "user.email=tester@example.com",
+		"-c", "commit.gpgsign=false", "commit", "--quiet", "-m", "dot")
+	assert.NoError(t, err)
+
+	ldr := NewFsLoader(afero.NewOsFs())
+	fld, err := ldr.LoadGitRef(work, "HEAD", ".github/docs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, NewFolder(filepath.Join(".github", "docs")).
+		AddFileObject(NewFile("x.md", []byte("# .github/docs/x.md"))).Equals(fld))
+	local, err := ldr.LoadFolder(filepath.Join(work, ".github", "docs"))
+	assert.NoError(t, err)
+	local.name = fld.name
+	assert.True(t, local.Equals(fld))
+
+	// A file named directly is loaded too.
+	fld, err = ldr.LoadGitRef(work, "HEAD", ".github/docs/x.md")
+	if !assert.NoError(t, err) {
+		return
+	}
+	local, err = ldr.LoadFolder(filepath.Join(work, ".github", "docs", "x.md"))
+	assert.NoError(t, err)
+	local.name = fld.name
+	assert.True(t, local.Equals(fld))
+	assert.Equal(t, "# .github/docs/x.md", string(fld.Lessons()[0].C()))
+	fld, err = ldr.LoadGitRef(work, "HEAD", ".github/docs/.hidden/y.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "# .github/docs/.hidden/y.md", string(fld.Lessons()[0].C()))
+}
+
+func TestLoadTreeGitRefArg(t *testing.T) {
+	bare := makeBareRepo(t)
+	ldr := NewFsLoader(afero.NewOsFs())
+	fld, err := ldr.LoadTree(bare + "@v1/docs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, NewFolder("docs").
+		AddFileObject(NewFile("a.md", []byte("# v1 a"))).Equals(fld))
+	fld, err = ldr.LoadTree(bare + "@dev")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, fld.dirs[0].NumFiles())
+
+	_, err = ldr.LoadTree(bare + "@noSuchRef")
+	assert.ErrorContains(t, err, "unable to find commit")
+	_, err = ldr.LoadTree(filepath.Join(t.TempDir(), "notARepo@v1"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	ws := NewWorkspace(ldr)
+	r, err := ws.Add(bare + "@v1/docs")
+	assert.NoError(t, err)
+	assert.Equal(t, OriginGitRef, r.Origin.Kind)
+	again, err := ws.AddGitRef(bare, "v1", "docs/a.md")
+	assert.NoError(t, err)
+	assert.Same(t, r, again)
+}
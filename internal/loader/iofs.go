@@ -0,0 +1,45 @@
+package loader
+
+import (
+	"github.com/spf13/afero"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NewFsLoaderFromIOFS returns a loader, with default filters, that reads
+// from an io/fs.FS, e.g. an embed.FS, an fstest.MapFS or the value of
+// os.DirFS.
+//
+// An io/fs.FS has no notion of absolute paths, so "/", "." and
+// the empty string all mean the root of fsys, and "/foo" means "foo".
+func NewFsLoaderFromIOFS(fsys fs.FS) *FsLoader {
+	return NewFsLoader(ioFs{FromIOFS: afero.FromIOFS{FS: fsys}})
+}
+
+// ioFs converts the paths used by the loader to the unrooted,
+// slash-separated paths required by io/fs.
+type ioFs struct {
+	afero.FromIOFS
+}
+
+func ioFsPath(name string) string {
+	name = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(name)), "/")
+	if name == "" {
+		return currentDir
+	}
+	return name
+}
+
+func (f ioFs) Open(name string) (afero.File, error) {
+	return f.FromIOFS.Open(ioFsPath(name))
+}
+
+func (f ioFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return f.FromIOFS.OpenFile(ioFsPath(name), flag, perm)
+}
+
+func (f ioFs) Stat(name string) (os.FileInfo, error) {
+	return f.FromIOFS.Stat(ioFsPath(name))
+}
@@ -0,0 +1,58 @@
+package loader_test
+
+import (
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewFsLoaderFromIOFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"f00.md":                  {Data: md[0].C()},
+		"aaa/f01.md":              {Data: md[1].C()},
+		"aaa/f02.md":              {Data: md[2].C()},
+		"aaa/ignore":              {Data: []byte("not markdown")},
+		"aaa/" + OrderingFileName: {Data: []byte("f02.md")},
+		".hidden/f03.md":          {Data: md[3].C()},
+	}
+	aaa := func() *MyFolder {
+		return NewFolder("aaa").AddFileObject(md[2]).AddFileObject(md[1])
+	}
+	for n, tc := range map[string]struct {
+		pathToLoad  string
+		expectedFld func() *MyFolder
+	}{
+		"empty": {
+			pathToLoad: "",
+			expectedFld: func() *MyFolder {
+				return NewFolder(".").AddFileObject(md[0]).AddFolderObject(aaa())
+			},
+		},
+		"slash": {
+			pathToLoad: "/",
+			expectedFld: func() *MyFolder {
+				return NewFolder("/").AddFileObject(md[0]).AddFolderObject(aaa())
+			},
+		},
+		"folder": {
+			pathToLoad:  "aaa",
+			expectedFld: aaa,
+		},
+		"file": {
+			pathToLoad: "/aaa/f01.md",
+			expectedFld: func() *MyFolder {
+				return NewFolder("/aaa").AddFileObject(md[1])
+			},
+		},
+	} {
+		t.Run(n, func(t *testing.T) {
+			fld, err := NewFsLoaderFromIOFS(fsys).LoadFolder(tc.pathToLoad)
+			assert.NoError(t, err)
+			if !assert.True(t, tc.expectedFld().Equals(fld)) {
+				t.Log("Loaded:")
+				fld.Accept(NewVisitorDump())
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package loader
+
+import "fmt"
+
+// Defaults for the loader's limits.  They're generous for documentation,
+// but keep a mistaken argument like "/" from reading a whole disk.
+const (
+	DefaultMaxFileSize   = 8 << 20
+	DefaultMaxDepth      = 32
+	DefaultMaxFiles      = 20000
+	DefaultMaxTotalBytes = 256 << 20
+)
+
+// LimitError reports that loading stopped because
+// the loader's MaxFiles or MaxTotalBytes limit was hit.
+type LimitError struct {
+	// Limit names the limit, e.g. "MaxFiles".
+	Limit string
+	// Max is the value of the limit.
+	Max int64
+	// Path is where loading stopped.
+	Path string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf(
+		"stopped loading at %q; more than %s=%d", e.Path, e.Limit, e.Max)
+}
+
+// loadState tracks one call to LoadFolder.
+type loadState struct {
+	files   int
+	bytes   int64
+	skipped Diagnostics
+}
+
+// count counts a loaded file of the given size,
+// returning a *LimitError if that's too many files or bytes.
+func (fsl *FsLoader) count(st *loadState, path string, size int64) error {
+	st.files++
+	st.bytes += size
+	if fsl.MaxFiles > 0 && st.files > fsl.MaxFiles {
+		return &LimitError{Limit: "MaxFiles", Max: int64(fsl.MaxFiles), Path: path}
+	}
+	return fsl.checkTotalBytes(path, st.bytes)
+}
+
+// checkTotalBytes returns a *LimitError if n is more than MaxTotalBytes.
+func (fsl *FsLoader) checkTotalBytes(path string, n int64) error {
+	if fsl.MaxTotalBytes > 0 && n > fsl.MaxTotalBytes {
+		return &LimitError{Limit: "MaxTotalBytes", Max: fsl.MaxTotalBytes, Path: path}
+	}
+	return nil
+}
+
+// Skipped returns the files and folders that were skipped while loading
+// the tree below the folder, if the folder was returned by LoadFolder
+// (or something that calls it, like LoadTree).  See FsLoader.Diagnostics.
+func (fl *MyFolder) Skipped() Diagnostics {
+	return fl.skipped
+}
@@ -0,0 +1,110 @@
+package loader_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func makeDeepFs(t *testing.T) afero.Fs {
+	fs := afero.NewMemMapFs()
+	for _, p := range []string{
+		"/top/a.md", "/top/big.md", "/top/b/b.md", "/top/b/c/c.md", "/top/b/c/d/d.md",
+	} {
+		c := "# " + p
+		if strings.HasSuffix(p, "big.md") {
+			c = strings.Repeat("x", 100)
+		}
+		assert.NoError(t, afero.WriteFile(fs, p, []byte(c), RW))
+	}
+	return fs
+}
+
+func TestLimitsSkip(t *testing.T) {
+	ldr := NewFsLoader(makeDeepFs(t))
+	assert.Equal(t, DefaultMaxDepth, ldr.MaxDepth)
+	fld, err := ldr.LoadTree("/top")
+	assert.NoError(t, err)
+	assert.Len(t, fld.Lessons(), 5)
+	assert.Empty(t, fld.Skipped())
+
+	ldr.MaxDepth = 2
+	ldr.MaxFileSize = 50
+	fld, err = ldr.LoadTree("/top")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/top/a.md", "/top/b/b.md", "/top/b/c/c.md"}, fullNames(fld.Lessons()))
+	var got []string
+	for _, d := range fld.Skipped() {
+		got = append(got, d.Kind.String()+" "+d.Path)
+	}
+	assert.Equal(t, []string{
+		"folder too deep /top/b/c/d",
+		"file too large /top/big.md",
+	}, got)
+	assert.Equal(t, fld.Skipped(), ldr.Diagnostics())
+}
+
+func TestLimitsStop(t *testing.T) {
+	for n, tc := range map[string]struct {
+		set   func(*FsLoader)
+		limit string
+	}{
+		"files": {set: func(l *FsLoader) { l.MaxFiles = 3 }, limit: "MaxFiles"},
+		"bytes": {set: func(l *FsLoader) { l.MaxTotalBytes = 120 }, limit: "MaxTotalBytes"},
+	} {
+		t.Run(n, func(t *testing.T) {
+			ldr := NewFsLoader(makeDeepFs(t))
+			tc.set(ldr)
+			_, err := ldr.LoadTree("/top")
+			var limitErr *LimitError
+			if assert.True(t, errors.As(err, &limitErr)) {
+				assert.Equal(t, tc.limit, limitErr.Limit)
+				assert.Contains(t, err.Error(), tc.limit)
+			}
+		})
+	}
+}
+
+func TestLimitsOnArchive(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, n := range []string{"a.md", "b.md", "huge.png", ".git/c.md", "x/.github/d.md"} {
+		w, err := zw.Create(n)
+		assert.NoError(t, err)
+		_, err = w.Write(bytes.Repeat([]byte("x"), 1000))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/docs.zip", buf.Bytes(), RW))
+
+	ldr := NewFsLoader(fs)
+	// The png, and markdown in dot folders, isn't unpacked, so doesn't count.
+	ldr.MaxTotalBytes = 2000
+	fld, err := ldr.LoadTree("/docs.zip")
+	assert.NoError(t, err)
+	assert.Len(t, fld.Lessons(), 2)
+
+	ldr.MaxTotalBytes = 1500
+	_, err = ldr.LoadTree("/docs.zip")
+	var limitErr *LimitError
+	if assert.True(t, errors.As(err, &limitErr)) {
+		assert.Equal(t, "MaxTotalBytes", limitErr.Limit)
+	}
+
+	// Files are counted as they're unpacked.
+	ldr.MaxTotalBytes = 0
+	ldr.MaxFiles = 2
+	_, err = ldr.LoadTree("/docs.zip")
+	assert.NoError(t, err)
+	ldr.MaxFiles = 1
+	_, err = ldr.LoadTree("/docs.zip")
+	if assert.True(t, errors.As(err, &limitErr)) {
+		assert.Equal(t, "MaxFiles", limitErr.Limit)
+	}
+}
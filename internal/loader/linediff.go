@@ -0,0 +1,235 @@
+package loader
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+type editKind int
+
+const (
+	editEqual editKind = iota
+	editDelete
+	editInsert
+)
+
+// edit is one step in turning sequence a into sequence b.
+// An equal edit keeps a[ia] (which equals b[ib]), a delete edit
+// drops a[ia], and an insert edit adds b[ib].
+type edit struct {
+	kind   editKind
+	ia, ib int
+}
+
+// maxEditDistance bounds the work diffSeq does, since the trace it
+// keeps takes space quadratic in the edit distance: about 8MB here.
+var maxEditDistance = 1000
+
+// diffSeq returns a shortest edit script turning a into b, using
+// the algorithm in Eugene Myers' "An O(ND) Difference Algorithm and
+// Its Variations".  Time is O((N+M)D) and space O(D²), so it's fast
+// for similar sequences, which is the common case when comparing docs.
+// If the edit distance is more than maxEditDistance, e.g. for a large
+// rewrite, the script simply deletes all of a and inserts all of b.
+func diffSeq[T any](a, b []T, eq func(x, y T) bool) []edit {
+	n, m := len(a), len(b)
+	mx := n + m
+	off := mx + 1
+	// v[off+k] is the furthest x reached on diagonal k.
+	v := make([]int, 2*mx+3)
+	// trace[d] holds v[off-d-1:off+d+2] as it was when round d began.
+	var trace [][]int
+	for d := 0; d <= mx; d++ {
+		if d > maxEditDistance {
+			return replaceAll(n, m)
+		}
+		trace = append(trace, slices.Clone(v[off-d-1:off+d+2]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && eq(a[x], b[y]) {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+// replaceAll returns an edit script deleting
+// all n items of a and inserting all m items of b.
+func replaceAll(n, m int) []edit {
+	edits := make([]edit, 0, n+m)
+	for i := 0; i < n; i++ {
+		edits = append(edits, edit{kind: editDelete, ia: i})
+	}
+	for i := 0; i < m; i++ {
+		edits = append(edits, edit{kind: editInsert, ia: n, ib: i})
+	}
+	return edits
+}
+
+func backtrack(trace [][]int, n, m int) []edit {
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		t := trace[d]
+		at := func(k int) int { return t[k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{kind: editEqual, ia: x, ib: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{kind: editInsert, ia: x, ib: y - 1})
+			} else {
+				edits = append(edits, edit{kind: editDelete, ia: x - 1, ib: y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	slices.Reverse(edits)
+	return edits
+}
+
+// DiffLine is one line of a line-level diff.
+type DiffLine struct {
+	// Op is '+' for an added line, '-' for a removed line,
+	// and ' ' for an unchanged line shown for context.
+	Op   byte
+	Text string
+}
+
+// DiffHunk is a group of nearby changed lines, with context.
+// Line numbers start at 1, as in the unified diff format.
+type DiffHunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []DiffLine
+}
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// splitLines splits content into lines, without their line endings.
+func splitLines(c []byte) []string {
+	if len(c) == 0 {
+		return nil
+	}
+	lines := strings.Split(string(c), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// DiffLines returns the hunks of a line-level diff between two contents.
+// It returns nil if they have the same lines.
+func DiffLines(oldC, newC []byte) []*DiffHunk {
+	a, b := splitLines(oldC), splitLines(newC)
+	edits := diffSeq(a, b, func(x, y string) bool { return x == y })
+	var (
+		hunks []*DiffHunk
+		h     *DiffHunk
+		// Index in edits of the last change added to h.
+		last int
+	)
+	for i, e := range edits {
+		if e.kind == editEqual {
+			continue
+		}
+		// Changes with no more unchanged lines between them than the
+		// context on both sides share a hunk, as with GNU diff.
+		if h != nil && i-last-1 > 2*diffContext {
+			closeHunk(h, a, edits, last)
+			hunks = append(hunks, h)
+			h = nil
+		}
+		if h == nil {
+			// Start a new hunk, with leading context.
+			start := max(i-diffContext, 0)
+			h = &DiffHunk{
+				OldStart: edits[start].ia + 1,
+				NewStart: edits[start].ib + 1,
+			}
+			last = start - 1
+		}
+		// Add what's between the last change and this one.
+		for _, c := range edits[last+1 : i+1] {
+			h.add(c, a, b)
+		}
+		last = i
+	}
+	if h != nil {
+		closeHunk(h, a, edits, last)
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// closeHunk adds trailing context to the hunk.
+func closeHunk(h *DiffHunk, a []string, edits []edit, last int) {
+	for _, c := range edits[last+1 : min(last+1+diffContext, len(edits))] {
+		if c.kind != editEqual {
+			break
+		}
+		h.add(c, a, nil)
+	}
+}
+
+func (h *DiffHunk) add(e edit, a, b []string) {
+	switch e.kind {
+	case editEqual:
+		h.Lines = append(h.Lines, DiffLine{Op: ' ', Text: a[e.ia]})
+		h.OldLines++
+		h.NewLines++
+	case editDelete:
+		h.Lines = append(h.Lines, DiffLine{Op: '-', Text: a[e.ia]})
+		h.OldLines++
+	case editInsert:
+		h.Lines = append(h.Lines, DiffLine{Op: '+', Text: b[e.ib]})
+		h.NewLines++
+	}
+}
+
+// Write writes the hunk in unified diff format.  As with GNU diff,
+// an empty range is numbered by the line before it, e.g. "-0,0"
+// for lines added to an empty file.
+func (h *DiffHunk) Write(w io.Writer) error {
+	oldStart, newStart := h.OldStart, h.NewStart
+	if h.OldLines == 0 {
+		oldStart--
+	}
+	if h.NewLines == 0 {
+		newStart--
+	}
+	if _, err := fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n",
+		oldStart, h.OldLines, newStart, h.NewLines); err != nil {
+		return err
+	}
+	for _, l := range h.Lines {
+		if _, err := fmt.Fprintf(w, "%c%s\n", l.Op, l.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
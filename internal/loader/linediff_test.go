@@ -0,0 +1,134 @@
+package loader
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestDiffSeq(t *testing.T) {
+	for n, tc := range map[string]struct {
+		a, b string
+	}{
+		"bothEmpty":  {},
+		"allNew":     {b: "abc"},
+		"allGone":    {a: "abc"},
+		"same":       {a: "abc", b: "abc"},
+		"classic":    {a: "ABCABBA", b: "CBABAC"},
+		"middle":     {a: "abcdef", b: "abXdef"},
+		"replaceAll": {a: "abc", b: "xyz"},
+	} {
+		t.Run(n, func(t *testing.T) {
+			a, b := []byte(tc.a), []byte(tc.b)
+			edits := diffSeq(a, b, func(x, y byte) bool { return x == y })
+			// Replay the edits to be sure they turn a into b.
+			var got []byte
+			equal := 0
+			for _, e := range edits {
+				switch e.kind {
+				case editEqual:
+					assert.Equal(t, a[e.ia], b[e.ib])
+					got = append(got, a[e.ia])
+					equal++
+				case editInsert:
+					got = append(got, b[e.ib])
+				}
+			}
+			assert.Equal(t, tc.b, string(got))
+			assert.Equal(t, len(a)+len(b)-2*equal, len(edits)-equal)
+		})
+	}
+	// The classic example has an edit distance of 5.
+	edits := diffSeq([]byte("ABCABBA"), []byte("CBABAC"),
+		func(x, y byte) bool { return x == y })
+	changes := 0
+	for _, e := range edits {
+		if e.kind != editEqual {
+			changes++
+		}
+	}
+	assert.Equal(t, 5, changes)
+}
+
+func TestDiffLines(t *testing.T) {
+	var old, nu []string
+	for i := 1; i <= 20; i++ {
+		old = append(old, "line"+string(rune('a'+i)))
+	}
+	nu = append(nu, old...)
+	nu[1] = "changed"
+	nu = append(nu[:15], nu[16:]...)
+	hunks := DiffLines(
+		[]byte(strings.Join(old, "\n")+"\n"), []byte(strings.Join(nu, "\n")+"\n"))
+	var buf bytes.Buffer
+	for _, h := range hunks {
+		assert.NoError(t, h.Write(&buf))
+	}
+	assert.Equal(t, `@@ -1,5 +1,5 @@
+ lineb
+-linec
++changed
+ lined
+ linee
+ linef
+@@ -13,7 +13,6 @@
+ linen
+ lineo
+ linep
+-lineq
+ liner
+ lines
+ linet
+`, buf.String())
+	assert.Nil(t, DiffLines([]byte("a\nb\n"), []byte("a\nb\n")))
+	assert.Len(t, DiffLines(nil, []byte("a\n")), 1)
+}
+
+func writeHunks(t *testing.T, hunks []*DiffHunk) string {
+	var buf bytes.Buffer
+	for _, h := range hunks {
+		assert.NoError(t, h.Write(&buf))
+	}
+	return buf.String()
+}
+
+func TestDiffLinesMergesNearbyChanges(t *testing.T) {
+	// Changes with six unchanged lines between them share a hunk.
+	old := "a\nb\nc\nd\ne\nf\ng\nh\n"
+	nu := "A\nb\nc\nd\ne\nf\ng\nH\n"
+	assert.Equal(t, `@@ -1,8 +1,8 @@
+-a
++A
+ b
+ c
+ d
+ e
+ f
+ g
+-h
++H
+`, writeHunks(t, DiffLines([]byte(old), []byte(nu))))
+	// With seven, they don't.
+	old = "a\nb\nc\nd\ne\nf\ng\nh\ni\n"
+	nu = "A\nb\nc\nd\ne\nf\ng\nh\nI\n"
+	assert.Len(t, DiffLines([]byte(old), []byte(nu)), 2)
+}
+
+func TestDiffLinesEmpty(t *testing.T) {
+	assert.Equal(t, "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		writeHunks(t, DiffLines(nil, []byte("a\nb\n"))))
+	assert.Equal(t, "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		writeHunks(t, DiffLines([]byte("a\nb\n"), nil)))
+}
+
+func TestDiffLinesLargeRewrite(t *testing.T) {
+	saved := maxEditDistance
+	maxEditDistance = 3
+	defer func() { maxEditDistance = saved }()
+	assert.Equal(t, "@@ -1,3 +1,3 @@\n-a\n-b\n-c\n+x\n+y\n+z\n",
+		writeHunks(t, DiffLines([]byte("a\nb\nc\n"), []byte("x\ny\nz\n"))))
+	// Small changes are still found.
+	assert.Equal(t, "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		writeHunks(t, DiffLines([]byte("a\nb\nc\n"), []byte("a\nB\nc\n"))))
+}
@@ -0,0 +1,90 @@
+package loader
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ManifestEntry describes a file or folder in a Manifest.
+type ManifestEntry struct {
+	// Path is slash separated, and relative to the manifest's root.
+	Path string `json:"path"`
+	// Folder is true for folders.
+	Folder bool `json:"folder,omitempty"`
+	// Size is the number of bytes in a file, or in the files below a folder.
+	Size int `json:"size"`
+	// Digest is the file's or folder's Digest.
+	Digest string `json:"digest"`
+}
+
+// Manifest records the structure and digests of a tree.
+// Publish it with a copy of the tree to make it easy
+// to confirm later that the copy matches its source.
+type Manifest struct {
+	// Algorithm is the hash used for the digests.
+	Algorithm string `json:"algorithm"`
+	// Root is the name of the root folder.
+	Root string `json:"root"`
+	// Digest is the root folder's Digest.
+	Digest string `json:"digest"`
+	// Size is the number of bytes in all the files.
+	Size int `json:"size"`
+	// Entries describe everything below the root, in depth-first order,
+	// with a folder's files before its folders.
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// NewManifest returns the manifest of the tree below the folder.
+func NewManifest(fl *MyFolder) *Manifest {
+	m := &Manifest{Algorithm: DigestAlgorithm, Root: fl.name}
+	m.Digest, m.Size = fl.digest("", m)
+	return m
+}
+
+// ReadManifest reads a manifest written by Manifest.Write.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Write writes the manifest as indented JSON.
+func (m *Manifest) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// Mismatches returns the paths of the files and folders whose digests
+// differ between the manifests, or that are in only one of them.
+// It returns nil if the manifests have the same digest.  The empty
+// path stands for the root, which differs if only the order of the
+// root's files or folders differs.
+func (m *Manifest) Mismatches(other *Manifest) []string {
+	if m.Digest == other.Digest {
+		return nil
+	}
+	theirs := make(map[string]string)
+	for _, e := range other.Entries {
+		theirs[e.Path] = e.Digest
+	}
+	var result []string
+	for _, e := range m.Entries {
+		d, ok := theirs[e.Path]
+		if !ok || d != e.Digest {
+			result = append(result, e.Path)
+		}
+		delete(theirs, e.Path)
+	}
+	for _, e := range other.Entries {
+		if _, ok := theirs[e.Path]; ok {
+			result = append(result, e.Path)
+		}
+	}
+	if result == nil {
+		result = []string{""}
+	}
+	return result
+}
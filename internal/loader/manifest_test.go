@@ -0,0 +1,90 @@
+package loader_test
+
+import (
+	"bytes"
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	assert.Equal(t,
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		NewEmptyFile("empty.md").Digest())
+	assert.Equal(t,
+		NewFile("a.md", []byte("x")).Digest(),
+		NewFile("b.md", []byte("x")).Digest())
+
+	// The root's name doesn't matter, but file contents do.
+	assert.Equal(t,
+		NewFolder("a").AddFileObject(NewFile("f.md", []byte("x"))).Digest(),
+		NewFolder("b").AddFileObject(NewFile("f.md", []byte("x"))).Digest())
+	assert.NotEqual(t,
+		NewFolder("a").AddFileObject(NewFile("f.md", []byte("x"))).Digest(),
+		NewFolder("a").AddFileObject(NewFile("f.md", []byte("y"))).Digest())
+
+	b1 := makeBenelux()
+	assert.Equal(t, b1.Digest(), makeBenelux().Digest())
+	for n, change := range map[string]func(*MyFolder){
+		"rename": func(fl *MyFolder) {
+			assert.NoError(t, fl.Rename("belgium/antwerp", "gent"))
+		},
+		"move": func(fl *MyFolder) {
+			assert.NoError(t, fl.Move("belgium/antwerp/diamonds", "netherlands"))
+		},
+		"remove": func(fl *MyFolder) {
+			_, err := fl.Remove("netherlands/drenthe")
+			assert.NoError(t, err)
+		},
+		"add": func(fl *MyFolder) {
+			fl.AddFileObject(NewEmptyFile("new.md"))
+		},
+	} {
+		t.Run(n, func(t *testing.T) {
+			fl := makeBenelux()
+			change(fl)
+			assert.NotEqual(t, b1.Digest(), fl.Digest())
+		})
+	}
+}
+
+func TestManifest(t *testing.T) {
+	fl := NewFolder("top").
+		AddFileObject(NewFile("a.md", []byte("aaa"))).
+		AddFolderObject(NewFolder("sub").
+			AddFileObject(NewFile("b.md", []byte("bb"))).
+			AddFolderObject(NewFolder("deeper").
+				AddFileObject(NewFile("c.md", []byte("c")))))
+	m := NewManifest(fl)
+	assert.Equal(t, "top", m.Root)
+	assert.Equal(t, DigestAlgorithm, m.Algorithm)
+	assert.Equal(t, fl.Digest(), m.Digest)
+	assert.Equal(t, 6, m.Size)
+	var paths []string
+	for _, e := range m.Entries {
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{
+		"a.md", "sub", "sub/b.md", "sub/deeper", "sub/deeper/c.md"}, paths)
+	assert.True(t, m.Entries[1].Folder)
+	assert.Equal(t, 3, m.Entries[1].Size)
+	assert.Equal(t, fl.Lookup("sub").(*MyFolder).Digest(), m.Entries[1].Digest)
+	assert.Equal(t, fl.Lookup("a").(*MyFile).Digest(), m.Entries[0].Digest)
+
+	var buf bytes.Buffer
+	assert.NoError(t, m.Write(&buf))
+	m2, err := ReadManifest(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, m, m2)
+	assert.Nil(t, m.Mismatches(m2))
+
+	_, err = fl.Remove("sub/deeper")
+	assert.NoError(t, err)
+	fl.AddFileObject(NewFile("d.md", nil))
+	assert.Equal(t,
+		[]string{"sub", "sub/deeper", "sub/deeper/c.md", "d.md"},
+		m2.Mismatches(NewManifest(fl)))
+
+	_, err = ReadManifest(bytes.NewBufferString("{"))
+	assert.Error(t, err)
+}
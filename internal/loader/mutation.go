@@ -0,0 +1,206 @@
+package loader
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	NoSuchNodeErr = fmt.Errorf("no such file or folder")
+	NameClashErr  = fmt.Errorf("name already used in folder")
+	BadNameErr    = fmt.Errorf("bad name")
+	BadMoveErr    = fmt.Errorf("cannot move a folder into itself")
+	NotAFolderErr = fmt.Errorf("not a folder")
+	CannotRootErr = fmt.Errorf("cannot remove, move or rename the folder itself")
+)
+
+// hasChild returns true if the folder has a file or folder with the name.
+func (fl *MyFolder) hasChild(name string) bool {
+	for _, fi := range fl.files {
+		if fi.name == name {
+			return true
+		}
+	}
+	for _, d := range fl.dirs {
+		if d.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// detach removes the node from its parent folder, if any.
+func detach(n MyTreeNode) {
+	p := parentFolder(n)
+	if p == nil {
+		return
+	}
+	switch x := n.(type) {
+	case *MyFile:
+		p.files = slices.DeleteFunc(p.files, func(fi *MyFile) bool { return fi == x })
+		x.parent = nil
+	case *MyFolder:
+		p.dirs = slices.DeleteFunc(p.dirs, func(d *MyFolder) bool { return d == x })
+		x.parent = nil
+	}
+	p.dropIndex()
+}
+
+// lookupBelow is Lookup, but only for nodes strictly below the folder.
+func (fl *MyFolder) lookupBelow(p string) (MyTreeNode, error) {
+	n := fl.Lookup(p)
+	if n == nil {
+		return nil, fmt.Errorf("%q; %w", p, NoSuchNodeErr)
+	}
+	if n == MyTreeNode(fl) {
+		return nil, CannotRootErr
+	}
+	return n, nil
+}
+
+// Remove removes the node at the path (see Lookup) from the tree,
+// returning the node.
+func (fl *MyFolder) Remove(p string) (MyTreeNode, error) {
+	n, err := fl.lookupBelow(p)
+	if err != nil {
+		return nil, err
+	}
+	detach(n)
+	return n, nil
+}
+
+// Move moves the node at path "from" into the folder at path "to"
+// (both relative to this folder; see Lookup).
+func (fl *MyFolder) Move(from, to string) error {
+	n, err := fl.lookupBelow(from)
+	if err != nil {
+		return err
+	}
+	dest, ok := fl.Lookup(to).(*MyFolder)
+	if !ok || dest == nil {
+		return fmt.Errorf("%q; %w", to, NotAFolderErr)
+	}
+	if d, isFolder := n.(*MyFolder); isFolder {
+		if dest == d || d.Ancestors(dest) != nil {
+			return fmt.Errorf("%q into %q; %w", from, to, BadMoveErr)
+		}
+	}
+	if parentFolder(n) == dest {
+		return nil
+	}
+	if dest.hasChild(n.Name()) {
+		return fmt.Errorf("%q in %q; %w", n.Name(), to, NameClashErr)
+	}
+	detach(n)
+	switch x := n.(type) {
+	case *MyFile:
+		dest.AddFileObject(x)
+	case *MyFolder:
+		dest.AddFolderObject(x)
+	}
+	return nil
+}
+
+// checkName returns a BadNameErr if the name can't be a file or folder name.
+func checkName(name string) error {
+	if name == "" || name == currentDir || name == upDir ||
+		strings.ContainsAny(name, "/"+rootSlash) {
+		return fmt.Errorf("%q; %w", name, BadNameErr)
+	}
+	return nil
+}
+
+// Rename gives the node at the path (see Lookup) a new name.
+func (fl *MyFolder) Rename(p, newName string) error {
+	if err := checkName(newName); err != nil {
+		return err
+	}
+	n, err := fl.lookupBelow(p)
+	if err != nil {
+		return err
+	}
+	if n.Name() == newName {
+		return nil
+	}
+	parent := parentFolder(n)
+	if parent.hasChild(newName) {
+		return fmt.Errorf("%q; %w", newName, NameClashErr)
+	}
+	switch x := n.(type) {
+	case *MyFile:
+		x.name = newName
+	case *MyFolder:
+		x.name = newName
+	}
+	parent.dropIndex()
+	return nil
+}
+
+// Sort sorts the files and folders in this folder and all folders below
+// it, using the given comparison functions (see slices.SortStableFunc).
+// A nil function leaves the order of that kind of node unchanged.
+func (fl *MyFolder) Sort(
+	cmpFiles func(a, b *MyFile) int, cmpFolders func(a, b *MyFolder) int) {
+	if cmpFiles != nil {
+		slices.SortStableFunc(fl.files, cmpFiles)
+	}
+	if cmpFolders != nil {
+		slices.SortStableFunc(fl.dirs, cmpFolders)
+	}
+	for _, d := range fl.dirs {
+		d.Sort(cmpFiles, cmpFolders)
+	}
+	fl.dropIndex()
+}
+
+// PruneEmpty removes all empty folders below this folder, including
+// folders that become empty because the folders in them were removed.
+func (fl *MyFolder) PruneEmpty() {
+	var kept []*MyFolder
+	for _, d := range fl.dirs {
+		d.PruneEmpty()
+		if d.IsEmpty() {
+			d.parent = nil
+		} else {
+			kept = append(kept, d)
+		}
+	}
+	fl.dirs = kept
+	fl.dropIndex()
+}
+
+// Clone returns a deep copy of the folder, with no parent.
+// The copies share file contents with the originals, so contents
+// shouldn't be modified in place.
+func (fl *MyFolder) Clone() *MyFolder {
+	result := NewFolder(fl.name)
+	result.skipped = fl.skipped
+	result.diskPath = fl.diskPath
+	for _, fi := range fl.files {
+		result.AddFileObject(fi.clone())
+	}
+	for _, d := range fl.dirs {
+		result.AddFolderObject(d.Clone())
+	}
+	return result
+}
+
+// Filter returns a copy of the folder holding only the files
+// for which keep returns true, and no empty folders.
+// The original tree is unchanged; the copy shares file contents with it.
+func (fl *MyFolder) Filter(keep func(*MyFile) bool) *MyFolder {
+	result := NewFolder(fl.name)
+	result.diskPath = fl.diskPath
+	for _, fi := range fl.files {
+		if keep(fi) {
+			result.AddFileObject(fi.clone())
+		}
+	}
+	for _, d := range fl.dirs {
+		if sub := d.Filter(keep); !sub.IsEmpty() {
+			result.AddFolderObject(sub)
+		}
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package loader_test
+
+import (
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRemove(t *testing.T) {
+	top := makeBenelux()
+	n, err := top.Remove("belgium/beer")
+	assert.NoError(t, err)
+	assert.Equal(t, "beer.md", n.Name())
+	assert.Nil(t, n.Parent())
+	assert.Nil(t, top.Lookup("belgium/beer"))
+	assert.NotContains(t, fullNames(top.Lessons()), "benelux/belgium/beer.md")
+
+	n, err = top.Remove("belgium/antwerp")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n.(*MyFolder).NumFiles())
+	assert.Nil(t, top.Lookup("belgium/antwerp/rubens"))
+
+	_, err = top.Remove("belgium/antwerp")
+	assert.ErrorIs(t, err, NoSuchNodeErr)
+	_, err = top.Remove("")
+	assert.ErrorIs(t, err, CannotRootErr)
+}
+
+func TestMove(t *testing.T) {
+	top := makeBenelux()
+	assert.NoError(t, top.Move("belgium/antwerp", "netherlands"))
+	assert.Equal(t, "benelux/netherlands/antwerp/diamonds.md",
+		top.Lookup("netherlands/antwerp/diamonds").FullName())
+	assert.Nil(t, top.Lookup("belgium/antwerp"))
+
+	assert.NoError(t, top.Move("history", "belgium"))
+	assert.Equal(t, "benelux/belgium/history.md", top.Lookup("belgium/history").FullName())
+	// Same folder is a no-op.
+	assert.NoError(t, top.Move("belgium/history", "belgium"))
+
+	assert.ErrorIs(t, top.Move("netherlands/README.md", ""), NameClashErr)
+	assert.ErrorIs(t, top.Move("netherlands", "netherlands/antwerp"), BadMoveErr)
+	assert.ErrorIs(t, top.Move("netherlands", "netherlands"), BadMoveErr)
+	assert.ErrorIs(t, top.Move("belgium", "README.md"), NotAFolderErr)
+	assert.ErrorIs(t, top.Move("luxembourg", ""), NoSuchNodeErr)
+}
+
+func TestRename(t *testing.T) {
+	top := makeBenelux()
+	assert.NoError(t, top.Rename("belgium/antwerp", "antwerpen"))
+	assert.Equal(t, "benelux/belgium/antwerpen/rubens.md",
+		top.Lookup("belgium/antwerpen/rubens").FullName())
+	assert.NoError(t, top.Rename("belgium/tintin", "kuifje.md"))
+	assert.NotNil(t, top.Lookup("belgium/kuifje"))
+
+	assert.ErrorIs(t, top.Rename("belgium/beer", "antwerpen"), NameClashErr)
+	assert.ErrorIs(t, top.Rename("belgium/beer", "a/b"), BadNameErr)
+	assert.ErrorIs(t, top.Rename("belgium/beer", ""), BadNameErr)
+	assert.ErrorIs(t, top.Rename(".", "x"), CannotRootErr)
+}
+
+func TestSort(t *testing.T) {
+	top := makeBenelux()
+	top.Sort(func(a, b *MyFile) int {
+		return strings.Compare(a.Name(), b.Name())
+	}, func(a, b *MyFolder) int {
+		return -strings.Compare(a.Name(), b.Name())
+	})
+	assert.Equal(t, []string{
+		"benelux/README.md",
+		"benelux/history.md",
+		"benelux/netherlands/README.md",
+		"benelux/netherlands/drenthe.md",
+		"benelux/belgium/beer.md",
+		"benelux/belgium/tintin.md",
+		"benelux/belgium/antwerp/README.md",
+		"benelux/belgium/antwerp/diamonds.md",
+		"benelux/belgium/antwerp/rubens.md",
+	}, fullNames(top.Lessons()))
+}
+
+func TestPruneEmpty(t *testing.T) {
+	top := makeBenelux()
+	top.AddFolderObject(NewFolder("luxembourg").AddFolderObject(NewFolder("empty")))
+	_, err := top.Remove("netherlands/README.md")
+	assert.NoError(t, err)
+	_, err = top.Remove("netherlands/drenthe.md")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, top.NumFolders())
+	top.PruneEmpty()
+	assert.Equal(t, 1, top.NumFolders())
+	assert.Nil(t, top.Lookup("luxembourg"))
+}
+
+func TestCloneAndFilter(t *testing.T) {
+	top := makeBenelux()
+	c := top.Clone()
+	assert.True(t, top.Equals(c))
+	assert.NotSame(t, top.Lookup("belgium/beer"), c.Lookup("belgium/beer"))
+
+	readmes := top.Filter(func(fi *MyFile) bool {
+		return fi.Name() == ReadmeFileName
+	})
+	assert.Equal(t, []string{
+		"benelux/README.md",
+		"benelux/belgium/antwerp/README.md",
+		"benelux/netherlands/README.md",
+	}, fullNames(readmes.Lessons()))
+	assert.Len(t, readmes.Lookup("belgium").(*MyFolder).Lessons(), 1)
+	// The original is untouched.
+	assert.Len(t, top.Lessons(), 9)
+
+	none := top.Filter(func(*MyFile) bool { return false })
+	assert.True(t, none.IsEmpty())
+	assert.Equal(t, "benelux", none.Name())
+}
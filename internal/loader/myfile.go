@@ -1,9 +1,16 @@
 package loader
 
+import (
+	"path/filepath"
+	"slices"
+)
+
 // MyFile is named byte array.
 type MyFile struct {
 	myTreeNode
 	content []byte
+	// norm records how the content was changed when loaded.
+	norm Normalization
 }
 
 var _ MyTreeNode = &MyFile{}
@@ -24,9 +31,29 @@ func (fi *MyFile) Accept(v TreeVisitor) {
 }
 
 // Load loads the file contents into the file object.
-func (fi *MyFile) Load(fsl *FsLoader) (err error) {
-	fi.content, err = fsl.fs.ReadFile(fi.FullName())
-	return
+// The contents are normalized; see Normalize.
+func (fi *MyFile) Load(fsl *FsLoader) error {
+	raw, err := fsl.fs.ReadFile(fi.diskPath())
+	if err != nil {
+		return err
+	}
+	fi.content, fi.norm, err = Normalize(raw)
+	return err
+}
+
+// diskPath returns the path the file was loaded from.  It's the
+// FullName, unless a folder holding the file was loaded from somewhere
+// its name doesn't show, e.g. because of FsLoader.DisplayRoot.
+func (fi *MyFile) diskPath() string {
+	names := []string{fi.name}
+	for fl := parentFolder(fi); fl != nil; fl = parentFolder(fl) {
+		if fl.diskPath != "" {
+			slices.Reverse(names)
+			return filepath.Join(append([]string{fl.diskPath}, names...)...)
+		}
+		names = append(names, fl.name)
+	}
+	return fi.FullName()
 }
 
 // C is the contents of the file.
@@ -34,6 +61,12 @@ func (fi *MyFile) C() []byte {
 	return fi.content
 }
 
+// Normalization says how the contents were changed when loaded.
+// Use its Restore method to get the contents as they were stored.
+func (fi *MyFile) Normalization() Normalization {
+	return fi.norm
+}
+
 // Equals checks for file equality
 func (fi *MyFile) Equals(other *MyFile) bool {
 	if fi == nil {
@@ -55,3 +88,10 @@ func (fi *MyFile) Equals(other *MyFile) bool {
 	}
 	return true
 }
+
+// clone returns a copy of the file, with no parent.
+func (fi *MyFile) clone() *MyFile {
+	c := NewFile(fi.name, fi.content)
+	c.norm = fi.norm
+	return c
+}
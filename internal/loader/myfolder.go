@@ -1,10 +1,23 @@
 package loader
 
+import "sync"
+
 // MyFolder is a named group of files and folders.
 type MyFolder struct {
 	myTreeNode
 	files []*MyFile
 	dirs  []*MyFolder
+
+	// skipped records what was skipped while loading the folder.
+	skipped Diagnostics
+
+	// diskPath, if not empty, is where the folder was loaded from,
+	// which its name needn't show; see FsLoader.DisplayRoot.
+	diskPath string
+
+	// index supports navigation; see treeIndex.
+	indexMu sync.Mutex
+	index   *treeIndex
 }
 
 var _ MyTreeNode = &MyFolder{}
@@ -20,6 +33,7 @@ func (fl *MyFolder) Accept(v TreeVisitor) {
 func (fl *MyFolder) AddFileObject(file *MyFile) *MyFolder {
 	file.parent = fl
 	fl.files = append(fl.files, file)
+	fl.dropIndex()
 	return fl
 }
 
@@ -52,6 +66,7 @@ func (fl *MyFolder) NumFolders() int {
 func (fl *MyFolder) AddFolderObject(folder *MyFolder) *MyFolder {
 	folder.parent = fl
 	fl.dirs = append(fl.dirs, folder)
+	fl.dropIndex()
 	return fl
 }
 
@@ -0,0 +1,128 @@
+package loader
+
+import (
+	"path"
+	"strings"
+)
+
+// treeIndex supports navigation in the tree below a folder.
+// It's built on first use, and dropped when the tree changes.
+type treeIndex struct {
+	// byPath maps slash separated paths relative to the
+	// indexed folder, e.g. "belgium/antwerp/diamonds.md", to nodes.
+	byPath map[string]MyTreeNode
+	// lessons are the files in depth-first order.
+	lessons []*MyFile
+	// position maps a lesson to its index in lessons.
+	position map[*MyFile]int
+}
+
+func newTreeIndex(fl *MyFolder) *treeIndex {
+	idx := &treeIndex{
+		byPath:   make(map[string]MyTreeNode),
+		position: make(map[*MyFile]int),
+	}
+	idx.byPath[""] = fl
+	idx.absorb(fl, "")
+	return idx
+}
+
+func (idx *treeIndex) absorb(fl *MyFolder, prefix string) {
+	for _, fi := range fl.files {
+		idx.byPath[prefix+fi.name] = fi
+		idx.position[fi] = len(idx.lessons)
+		idx.lessons = append(idx.lessons, fi)
+	}
+	for _, d := range fl.dirs {
+		idx.byPath[prefix+d.name] = d
+		idx.absorb(d, prefix+d.name+"/")
+	}
+}
+
+// navIndex returns the folder's index, building it if need be.
+func (fl *MyFolder) navIndex() *treeIndex {
+	fl.indexMu.Lock()
+	defer fl.indexMu.Unlock()
+	if fl.index == nil {
+		fl.index = newTreeIndex(fl)
+	}
+	return fl.index
+}
+
+// dropIndex drops the indices of the folder and all folders above it,
+// since a change to the folder changes the trees they index.
+func (fl *MyFolder) dropIndex() {
+	for f := fl; f != nil; f = parentFolder(f) {
+		f.indexMu.Lock()
+		f.index = nil
+		f.indexMu.Unlock()
+	}
+}
+
+// parentFolder returns the node's parent as a folder, or nil.
+func parentFolder(n MyTreeNode) *MyFolder {
+	if p, ok := n.Parent().(*MyFolder); ok {
+		return p
+	}
+	return nil
+}
+
+// Lookup returns the node at the slash separated path relative to the
+// folder, e.g. "belgium/antwerp/diamonds.md", or nil if there's no such
+// node.  The ".md" extension may be omitted, so "belgium/antwerp/diamonds"
+// works too.  The empty path, "." and "/" return the folder itself.
+func (fl *MyFolder) Lookup(p string) MyTreeNode {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	idx := fl.navIndex()
+	if n, ok := idx.byPath[p]; ok {
+		return n
+	}
+	if n, ok := idx.byPath[p+".md"]; ok {
+		return n
+	}
+	return nil
+}
+
+// Lessons returns all files at or below the folder, in depth-first order,
+// i.e. the order in which one would read them as a book.
+func (fl *MyFolder) Lessons() []*MyFile {
+	return fl.navIndex().lessons
+}
+
+// Next returns the lesson after the given one, or nil if the given
+// file is the last lesson or isn't below the folder.
+func (fl *MyFolder) Next(fi *MyFile) *MyFile {
+	idx := fl.navIndex()
+	if i, ok := idx.position[fi]; ok && i+1 < len(idx.lessons) {
+		return idx.lessons[i+1]
+	}
+	return nil
+}
+
+// Prev returns the lesson before the given one, or nil if the given
+// file is the first lesson or isn't below the folder.
+func (fl *MyFolder) Prev(fi *MyFile) *MyFile {
+	idx := fl.navIndex()
+	if i, ok := idx.position[fi]; ok && i > 0 {
+		return idx.lessons[i-1]
+	}
+	return nil
+}
+
+// Ancestors returns the folders holding the node, starting with this
+// folder and ending with the node's parent, e.g. the folders to expand in a
+// left nav to show the node.  It returns nil if the node isn't below this
+// folder (or is this folder).
+func (fl *MyFolder) Ancestors(n MyTreeNode) []*MyFolder {
+	var result []*MyFolder
+	for p := parentFolder(n); p != nil; p = parentFolder(p) {
+		result = append(result, p)
+		if p == fl {
+			for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+				result[i], result[j] = result[j], result[i]
+			}
+			return result
+		}
+	}
+	return nil
+}
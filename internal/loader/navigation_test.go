@@ -0,0 +1,109 @@
+package loader_test
+
+import (
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// makeBenelux makes the tree described in internal/doc.go.
+func makeBenelux() *MyFolder {
+	antwerp := NewFolder("antwerp").
+		AddFileObject(NewEmptyFile("README.md")).
+		AddFileObject(NewEmptyFile("diamonds.md")).
+		AddFileObject(NewEmptyFile("rubens.md"))
+	belgium := NewFolder("belgium").
+		AddFileObject(NewEmptyFile("tintin.md")).
+		AddFileObject(NewEmptyFile("beer.md")).
+		AddFolderObject(antwerp)
+	netherlands := NewFolder("netherlands").
+		AddFileObject(NewEmptyFile("README.md")).
+		AddFileObject(NewEmptyFile("drenthe.md"))
+	return NewFolder("benelux").
+		AddFileObject(NewEmptyFile("README.md")).
+		AddFileObject(NewEmptyFile("history.md")).
+		AddFolderObject(belgium).
+		AddFolderObject(netherlands)
+}
+
+func fullNames(files []*MyFile) (result []string) {
+	for _, fi := range files {
+		result = append(result, fi.FullName())
+	}
+	return
+}
+
+func TestLookup(t *testing.T) {
+	top := makeBenelux()
+	for p, want := range map[string]string{
+		"":                             "benelux",
+		"/":                            "benelux",
+		".":                            "benelux",
+		"belgium":                      "benelux/belgium",
+		"belgium/antwerp/diamonds":     "benelux/belgium/antwerp/diamonds.md",
+		"/belgium/antwerp/diamonds.md": "benelux/belgium/antwerp/diamonds.md",
+		"belgium/antwerp/":             "benelux/belgium/antwerp",
+		"netherlands/../history":       "benelux/history.md",
+	} {
+		n := top.Lookup(p)
+		if assert.NotNil(t, n, p) {
+			assert.Equal(t, want, n.FullName(), p)
+		}
+	}
+	assert.Nil(t, top.Lookup("belgium/ghent"))
+	assert.Nil(t, top.Lookup("antwerp"))
+
+	belgium := top.Lookup("belgium").(*MyFolder)
+	assert.Equal(t, "benelux/belgium/antwerp/rubens.md",
+		belgium.Lookup("antwerp/rubens").FullName())
+
+	// The index tracks changes.
+	belgium.AddFileObject(NewEmptyFile("brabant.md"))
+	assert.Equal(t, "benelux/belgium/brabant.md", top.Lookup("belgium/brabant").FullName())
+	assert.Equal(t, "benelux/belgium/brabant.md", belgium.Lookup("brabant").FullName())
+}
+
+func TestLessonsAndNavigation(t *testing.T) {
+	top := makeBenelux()
+	lessons := top.Lessons()
+	assert.Equal(t, []string{
+		"benelux/README.md",
+		"benelux/history.md",
+		"benelux/belgium/tintin.md",
+		"benelux/belgium/beer.md",
+		"benelux/belgium/antwerp/README.md",
+		"benelux/belgium/antwerp/diamonds.md",
+		"benelux/belgium/antwerp/rubens.md",
+		"benelux/netherlands/README.md",
+		"benelux/netherlands/drenthe.md",
+	}, fullNames(lessons))
+
+	assert.Nil(t, top.Prev(lessons[0]))
+	assert.Nil(t, top.Next(lessons[len(lessons)-1]))
+	for i := 1; i < len(lessons); i++ {
+		assert.Same(t, lessons[i], top.Next(lessons[i-1]))
+		assert.Same(t, lessons[i-1], top.Prev(lessons[i]))
+	}
+	// Navigation is limited to the folder's subtree.
+	belgium := top.Lookup("belgium").(*MyFolder)
+	rubens := top.Lookup("belgium/antwerp/rubens").(*MyFile)
+	assert.Nil(t, belgium.Next(rubens))
+	assert.Nil(t, belgium.Next(lessons[0]))
+	assert.Equal(t, "benelux/netherlands/README.md", top.Next(rubens).FullName())
+}
+
+func TestAncestors(t *testing.T) {
+	top := makeBenelux()
+	diamonds := top.Lookup("belgium/antwerp/diamonds")
+	var names []string
+	for _, fl := range top.Ancestors(diamonds) {
+		names = append(names, fl.Name())
+	}
+	assert.Equal(t, []string{"benelux", "belgium", "antwerp"}, names)
+
+	belgium := top.Lookup("belgium").(*MyFolder)
+	assert.Len(t, belgium.Ancestors(diamonds), 2)
+	assert.Nil(t, belgium.Ancestors(top.Lookup("history")))
+	assert.Nil(t, top.Ancestors(top))
+	assert.Equal(t, []*MyFolder{top}, top.Ancestors(belgium))
+}
@@ -1,6 +1,7 @@
 package loader
 
 import (
+	"fmt"
 	"github.com/spf13/afero"
 	"os"
 	"strings"
@@ -24,6 +25,9 @@ func LoadOrderFile(fs *afero.Afero, path string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	if contents, _, err = Normalize(contents); err != nil {
+		return nil, fmt.Errorf("ordering file %q; %w", path, err)
+	}
 	return strings.Split(string(contents), "\n"), nil
 }
 
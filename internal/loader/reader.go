@@ -0,0 +1,54 @@
+package loader
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	// StdinArg is the LoadTree argument meaning "read standard input".
+	StdinArg = "-"
+	// StdinFileName is the name given to markdown read from standard input.
+	StdinFileName = "stdin.md"
+)
+
+// LoadReader reads one markdown document from the reader, returning
+// a folder named "." that holds it as a file with the given name.
+// The document is not subject to the loader's file filter, since
+// there's no file to filter.  The document is normalized; see Normalize.
+//
+// The loader's limits apply as they do to a file named to LoadFolder:
+// since the document was asked for, one larger than MaxFileSize is an
+// error (a *Diagnostic), even when continuing on error; one larger than
+// MaxTotalBytes gets a *LimitError.  No more of the reader is read than
+// needed to tell.
+func (fsl *FsLoader) LoadReader(r io.Reader, name string) (*MyFolder, error) {
+	var limit int64
+	for _, n := range []int64{fsl.MaxFileSize, fsl.MaxTotalBytes} {
+		if n > 0 && (limit == 0 || n < limit) {
+			limit = n
+		}
+	}
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	c, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if fsl.MaxFileSize > 0 && int64(len(c)) > fsl.MaxFileSize {
+		return nil, &Diagnostic{
+			Path: name,
+			Kind: DiagFileTooLarge,
+			Err:  fmt.Errorf("more than the limit of %d bytes", fsl.MaxFileSize),
+		}
+	}
+	if err = fsl.checkTotalBytes(name, int64(len(c))); err != nil {
+		return nil, err
+	}
+	fi, err := newLoadedFile(name, c)
+	if err != nil {
+		return nil, fmt.Errorf("%s; %w", name, err)
+	}
+	return NewFolder(fsl.displayName(currentDir)).AddFileObject(fi), nil
+}
@@ -0,0 +1,77 @@
+package loader_test
+
+import (
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadReader(t *testing.T) {
+	ldr := NewFsLoader(afero.NewMemMapFs())
+	fld, err := ldr.LoadReader(strings.NewReader("# file f01"), "f01.md")
+	assert.NoError(t, err)
+	assert.True(t, NewFolder(".").AddFileObject(md[1]).Equals(fld))
+
+	ldr.DisplayRoot = "piped"
+	fld, err = ldr.LoadReader(strings.NewReader(""), StdinFileName)
+	assert.NoError(t, err)
+	assert.True(t, NewFolder("piped").AddFileObject(NewEmptyFile(StdinFileName)).Equals(fld))
+
+	fld, err = ldr.LoadReader(strings.NewReader("# file f01\r\n"), "f01.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "# file f01\n", string(fld.Lookup("f01").(*MyFile).C()))
+
+	_, err = ldr.LoadReader(strings.NewReader("caf\xE9"), StdinFileName)
+	assert.ErrorIs(t, err, InvalidEncodingErr)
+}
+
+func TestLoadReaderLimits(t *testing.T) {
+	ldr := NewFsLoader(afero.NewMemMapFs())
+	ldr.MaxFileSize = 5
+	for _, keepGoing := range []bool{false, true} {
+		ldr.ContinueOnError = keepGoing
+		fld, err := ldr.LoadReader(strings.NewReader("# file f01"), StdinFileName)
+		assert.Nil(t, fld)
+		var d *Diagnostic
+		if assert.ErrorAs(t, err, &d) {
+			assert.Equal(t, DiagFileTooLarge, d.Kind)
+			assert.Equal(t, StdinFileName, d.Path)
+			assert.Contains(t, err.Error(), "more than the limit of 5 bytes")
+		}
+	}
+	ldr.ContinueOnError = false
+
+	ldr.MaxFileSize = 0
+	ldr.MaxTotalBytes = 5
+	_, err := ldr.LoadReader(strings.NewReader("# file f01"), StdinFileName)
+	var limitErr *LimitError
+	assert.ErrorAs(t, err, &limitErr)
+
+	ldr.MaxTotalBytes = 10
+	_, err = ldr.LoadReader(strings.NewReader("# file f01"), StdinFileName)
+	assert.NoError(t, err)
+}
+
+func TestWorkspaceStdinOnce(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "in.md")
+	assert.NoError(t, os.WriteFile(p, []byte("# piped"), RW))
+	f, err := os.Open(p)
+	assert.NoError(t, err)
+	defer f.Close()
+	saved := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = saved }()
+
+	ws := NewWorkspace(NewFsLoader(afero.NewOsFs()))
+	r1, err := ws.Add(StdinArg)
+	assert.NoError(t, err)
+	r2, err := ws.Add(StdinArg)
+	assert.NoError(t, err)
+	assert.Same(t, r1, r2)
+	assert.Len(t, ws.Roots(), 1)
+	assert.Equal(t, "# piped", string(r1.Folder.Lessons()[0].C()))
+}
@@ -0,0 +1,202 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// SnapshotFormat is an encoding of a snapshot.
+type SnapshotFormat int
+
+const (
+	// SnapshotJSON is JSON, with file contents in base64.
+	SnapshotJSON SnapshotFormat = iota
+	// SnapshotGob is encoding/gob, which is smaller and faster.
+	SnapshotGob
+)
+
+const (
+	// SnapshotExtJSON is the extension LoadTree recognizes as a JSON snapshot.
+	SnapshotExtJSON = ".mdsnap.json"
+	// SnapshotExtGob is the extension LoadTree recognizes as a gob snapshot.
+	SnapshotExtGob = ".mdsnap.gob"
+	// snapshotVersion changes when the snapshot encoding does.
+	snapshotVersion = 1
+)
+
+var BadSnapshotErr = fmt.Errorf("bad snapshot")
+
+// SnapshotFormatOf returns the format implied by a path's extension.
+func SnapshotFormatOf(path string) (SnapshotFormat, bool) {
+	p := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(p, SnapshotExtJSON):
+		return SnapshotJSON, true
+	case strings.HasSuffix(p, SnapshotExtGob):
+		return SnapshotGob, true
+	default:
+		return SnapshotJSON, false
+	}
+}
+
+// snapshot is the encoded form of a tree.
+type snapshot struct {
+	Version int         `json:"version"`
+	Root    *snapFolder `json:"root"`
+}
+
+type snapFolder struct {
+	Name    string        `json:"name"`
+	Files   []*snapFile   `json:"files,omitempty"`
+	Folders []*snapFolder `json:"folders,omitempty"`
+	Skipped []*snapSkip   `json:"skipped,omitempty"`
+}
+
+type snapFile struct {
+	Name    string `json:"name"`
+	Content []byte `json:"content"`
+	// Digest guards against corrupted or hand edited snapshots.
+	Digest        string         `json:"digest"`
+	Normalization *Normalization `json:"normalization,omitempty"`
+}
+
+type snapSkip struct {
+	Path    string         `json:"path"`
+	Kind    DiagnosticKind `json:"kind"`
+	Message string         `json:"message"`
+}
+
+func newSnapFolder(fl *MyFolder) *snapFolder {
+	result := &snapFolder{Name: fl.name}
+	for _, fi := range fl.files {
+		sf := &snapFile{Name: fi.name, Content: fi.content, Digest: fi.Digest()}
+		if !fi.norm.IsZero() {
+			n := fi.norm
+			sf.Normalization = &n
+		}
+		result.Files = append(result.Files, sf)
+	}
+	for _, d := range fl.dirs {
+		result.Folders = append(result.Folders, newSnapFolder(d))
+	}
+	for _, d := range fl.skipped {
+		result.Skipped = append(result.Skipped, &snapSkip{
+			Path: d.Path, Kind: d.Kind, Message: d.Err.Error()})
+	}
+	return result
+}
+
+func (sf *snapFolder) folder() (*MyFolder, error) {
+	result := NewFolder(sf.Name)
+	for _, x := range sf.Files {
+		if x == nil {
+			return nil, fmt.Errorf("nil file in %q; %w", sf.Name, BadSnapshotErr)
+		}
+		fi := NewFile(x.Name, x.Content)
+		if fi.Digest() != x.Digest {
+			return nil, fmt.Errorf(
+				"digest mismatch for %q; %w", x.Name, BadSnapshotErr)
+		}
+		if x.Normalization != nil {
+			fi.norm = *x.Normalization
+		}
+		result.AddFileObject(fi)
+	}
+	for _, x := range sf.Folders {
+		if x == nil {
+			return nil, fmt.Errorf("nil folder in %q; %w", sf.Name, BadSnapshotErr)
+		}
+		sub, err := x.folder()
+		if err != nil {
+			return nil, err
+		}
+		result.AddFolderObject(sub)
+	}
+	for _, x := range sf.Skipped {
+		result.skipped = append(result.skipped, &Diagnostic{
+			Path: x.Path, Kind: x.Kind, Err: errors.New(x.Message)})
+	}
+	return result, nil
+}
+
+// WriteSnapshot writes everything needed to rebuild the tree below the
+// folder: names, order, file contents and normalizations (see Normalize),
+// and what was skipped while loading (see Skipped).
+func (fl *MyFolder) WriteSnapshot(w io.Writer, format SnapshotFormat) error {
+	s := &snapshot{Version: snapshotVersion, Root: newSnapFolder(fl)}
+	switch format {
+	case SnapshotJSON:
+		return json.NewEncoder(w).Encode(s)
+	case SnapshotGob:
+		return gob.NewEncoder(w).Encode(s)
+	default:
+		return fmt.Errorf("unknown snapshot format %d", format)
+	}
+}
+
+// ReadSnapshot rebuilds a tree written by WriteSnapshot.
+func ReadSnapshot(r io.Reader, format SnapshotFormat) (*MyFolder, error) {
+	var (
+		s   snapshot
+		err error
+	)
+	switch format {
+	case SnapshotJSON:
+		err = json.NewDecoder(r).Decode(&s)
+	case SnapshotGob:
+		err = gob.NewDecoder(r).Decode(&s)
+	default:
+		return nil, fmt.Errorf("unknown snapshot format %d", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w; %w", BadSnapshotErr, err)
+	}
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf(
+			"version %d, want %d; %w", s.Version, snapshotVersion, BadSnapshotErr)
+	}
+	if s.Root == nil {
+		return nil, fmt.Errorf("no root; %w", BadSnapshotErr)
+	}
+	return s.Root.folder()
+}
+
+// LoadSnapshot loads a tree from a snapshot file, whose format is
+// given by its extension (SnapshotExtJSON or SnapshotExtGob).
+//
+// The tree is returned as it was when the snapshot was written; the
+// loader's filters and limits aren't applied, except for MaxTotalBytes,
+// which limits the size of the snapshot file.  The returned folder keeps
+// the name it had when written, unless DisplayRoot is set.
+func (fsl *FsLoader) LoadSnapshot(rawPath string) (*MyFolder, error) {
+	cleanPath := filepath.Clean(rawPath)
+	format, ok := SnapshotFormatOf(cleanPath)
+	if !ok {
+		return nil, fmt.Errorf("unrecognized snapshot extension in %q", cleanPath)
+	}
+	info, err := fsl.fs.Stat(cleanPath)
+	if err != nil {
+		return nil, err
+	}
+	if err = fsl.checkTotalBytes(cleanPath, info.Size()); err != nil {
+		return nil, err
+	}
+	c, err := fsl.fs.ReadFile(cleanPath)
+	if err != nil {
+		return nil, err
+	}
+	fld, err := ReadSnapshot(bytes.NewReader(c), format)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %q; %w", cleanPath, err)
+	}
+	if fsl.DisplayRoot != "" {
+		fld.name = fsl.DisplayRoot
+	}
+	return fld, nil
+}
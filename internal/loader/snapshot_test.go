@@ -0,0 +1,78 @@
+package loader_test
+
+import (
+	"bytes"
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for p, c := range map[string]string{
+		"/docs/zebra.md":              "# zebra\r\n",
+		"/docs/apple.md":              "\xEF\xBB\xBF# apple",
+		"/docs/big.md":                strings.Repeat("x", 100),
+		"/docs/sub/s.md":              "# s",
+		"/docs/" + OrderingFileName:   "zebra\nsub",
+		"/docs/sub/deeper/ignored.md": "# too deep",
+	} {
+		assert.NoError(t, afero.WriteFile(fs, p, []byte(c), RW))
+	}
+	ldr := NewFsLoader(fs)
+	ldr.MaxFileSize = 50
+	ldr.MaxDepth = 1
+	orig, err := ldr.LoadTree("/docs")
+	assert.NoError(t, err)
+	assert.Len(t, orig.Skipped(), 2)
+
+	for n, tc := range map[string]struct {
+		format SnapshotFormat
+		path   string
+	}{
+		"json": {format: SnapshotJSON, path: "/out/docs" + SnapshotExtJSON},
+		"gob":  {format: SnapshotGob, path: "/out/docs" + SnapshotExtGob},
+	} {
+		t.Run(n, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, orig.WriteSnapshot(&buf, tc.format))
+			assert.NoError(t, afero.WriteFile(fs, tc.path, buf.Bytes(), RW))
+
+			fld, err := NewFsLoader(fs).LoadTree(tc.path)
+			assert.NoError(t, err)
+			assert.True(t, orig.Equals(fld))
+			assert.Equal(t, "/docs", fld.Name())
+			assert.Equal(t, orig.Digest(), fld.Digest())
+			assert.Equal(t, fullNames(orig.Lessons()), fullNames(fld.Lessons()))
+			for i, fi := range fld.Lessons() {
+				assert.Equal(t, orig.Lessons()[i].Normalization(), fi.Normalization())
+			}
+			if assert.Len(t, fld.Skipped(), 2) {
+				assert.Equal(t, orig.Skipped()[0].Path, fld.Skipped()[0].Path)
+				assert.Equal(t, orig.Skipped()[0].Kind, fld.Skipped()[0].Kind)
+				assert.Equal(t, orig.Skipped()[0].Error(), fld.Skipped()[0].Error())
+			}
+		})
+	}
+}
+
+func TestReadSnapshotErrors(t *testing.T) {
+	fld := NewFolder("top").AddFileObject(NewFile("a.md", []byte("# a")))
+	var buf bytes.Buffer
+	assert.NoError(t, fld.WriteSnapshot(&buf, SnapshotJSON))
+	good := buf.String()
+
+	for n, s := range map[string]string{
+		"garbage":  "{",
+		"version":  strings.Replace(good, `"version":1`, `"version":99`, 1),
+		"noRoot":   `{"version":1}`,
+		"tampered": strings.Replace(good, `"content":"IyBh"`, `"content":"IyBi"`, 1),
+	} {
+		_, err := ReadSnapshot(strings.NewReader(s), SnapshotJSON)
+		assert.ErrorIs(t, err, BadSnapshotErr, n)
+	}
+	_, err := ReadSnapshot(strings.NewReader(good), SnapshotGob)
+	assert.ErrorIs(t, err, BadSnapshotErr)
+}
@@ -2,40 +2,57 @@ package loader
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 	"unicode"
 )
 
+// VisitorDump prints a tree, one line per file or folder,
+// indented by depth.  See also MyFolder.Dump.
 type VisitorDump struct {
-	indent int
+	w io.Writer
 }
 
+// NewVisitorDump returns a VisitorDump that prints to stdout.
 func NewVisitorDump() *VisitorDump {
-	return &VisitorDump{
-		indent: 0,
-	}
+	return NewVisitorDumpTo(os.Stdout)
 }
 
-const blanks = "                                                                "
+// NewVisitorDumpTo returns a VisitorDump that prints to the writer.
+func NewVisitorDumpTo(w io.Writer) *VisitorDump {
+	return &VisitorDump{w: w}
+}
 
 func (v *VisitorDump) VisitFolder(fl *MyFolder) {
-	fmt.Print(blanks[:v.indent])
-	fmt.Print(fl.Name())
+	_ = (&Walker{
+		EnterFolder: func(st *WalkState, fl *MyFolder) error {
+			v.dumpFolder(st.Depth(), fl)
+			return nil
+		},
+		VisitFile: func(st *WalkState, fi *MyFile) error {
+			v.dumpFile(st.Depth(), fi)
+			return nil
+		},
+	}).Walk(context.Background(), fl)
+}
+
+func (v *VisitorDump) VisitFile(fi *MyFile) {
+	v.dumpFile(0, fi)
+}
+
+func (v *VisitorDump) dumpFolder(depth int, fl *MyFolder) {
+	name := fl.Name()
 	if !fl.IsRoot() {
-		fmt.Print(rootSlash)
+		name += rootSlash
 	}
-	fmt.Println()
-	v.indent += 2
-	fl.VisitFiles(v)
-	fl.VisitFolders(v)
-	v.indent -= 2
+	fmt.Fprintln(v.w, strings.Repeat("  ", depth)+name)
 }
 
-func (v *VisitorDump) VisitFile(fi *MyFile) {
-	fmt.Print(blanks[:v.indent])
-	fmt.Print(fi.Name())
-	fmt.Print(" : ")
-	fmt.Println(summarize(fi.C()) + "...")
+func (v *VisitorDump) dumpFile(depth int, fi *MyFile) {
+	fmt.Fprintln(v.w, strings.Repeat("  ", depth)+fi.Name()+" : "+summarize(fi.C())+"...")
 }
 
 func summarize(c []byte) string {
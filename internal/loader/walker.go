@@ -0,0 +1,137 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	// SkipFolderErr, returned by a Walker callback, skips the rest of a
+	// folder.  From EnterFolder, it skips the folder entirely.  From
+	// VisitFile, it skips the rest of the file's folder, but its
+	// LeaveFolder is still called.  From LeaveFolder, it's ignored.
+	SkipFolderErr = errors.New("skip this folder")
+	// SkipAllErr, returned by a Walker callback, ends the walk early,
+	// without Walk returning an error.
+	SkipAllErr = errors.New("skip everything")
+)
+
+// Walker walks a tree depth first, calling its callbacks as it goes.
+// Files are visited before folders, in the order they're held,
+// which is the order of MyFolder.Lessons.
+//
+// Any callback may be nil.  A callback returning an error other than
+// SkipFolderErr or SkipAllErr stops the walk, and Walk returns the error.
+type Walker struct {
+	// EnterFolder is called on arriving at a folder,
+	// before anything in it is visited.
+	EnterFolder func(st *WalkState, fl *MyFolder) error
+	// LeaveFolder is called after everything in a folder is visited.
+	LeaveFolder func(st *WalkState, fl *MyFolder) error
+	// VisitFile is called for each file.
+	VisitFile func(st *WalkState, fi *MyFile) error
+}
+
+// WalkState says where a walk is.
+type WalkState struct {
+	ctx context.Context
+	// stack holds the folders from the one the walk started at
+	// down to the current node's parent.
+	stack []*MyFolder
+	node  MyTreeNode
+}
+
+// Context returns the context given to Walk.
+func (st *WalkState) Context() context.Context {
+	return st.ctx
+}
+
+// Depth returns how far the current node is below the folder the walk
+// started at: zero for the folder itself, one for what's in it, etc.
+func (st *WalkState) Depth() int {
+	return len(st.stack)
+}
+
+// Stack returns the folders holding the current node, starting with
+// the folder the walk started at and ending with the node's parent.
+// It's empty at the start folder.  The slice mustn't be kept, since
+// the walk reuses it.
+func (st *WalkState) Stack() []*MyFolder {
+	return st.stack
+}
+
+// Path returns the slash separated path to the current node, relative to
+// the folder the walk started at, e.g. "belgium/antwerp/diamonds.md".
+// It's empty at the start folder.
+func (st *WalkState) Path() string {
+	if len(st.stack) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, fl := range st.stack[1:] {
+		b.WriteString(fl.name)
+		b.WriteString("/")
+	}
+	b.WriteString(st.node.Name())
+	return b.String()
+}
+
+// Walk walks the tree below the folder, including the folder itself.
+// The walk stops, returning the context's error, if the context is
+// cancelled.
+func (w *Walker) Walk(ctx context.Context, fl *MyFolder) error {
+	err := w.walkFolder(&WalkState{ctx: ctx}, fl)
+	if errors.Is(err, SkipAllErr) {
+		return nil
+	}
+	return err
+}
+
+func (w *Walker) walkFolder(st *WalkState, fl *MyFolder) error {
+	if err := st.ctx.Err(); err != nil {
+		return err
+	}
+	st.node = fl
+	if w.EnterFolder != nil {
+		if err := w.EnterFolder(st, fl); err != nil {
+			if errors.Is(err, SkipFolderErr) {
+				return nil
+			}
+			return err
+		}
+	}
+	st.stack = append(st.stack, fl)
+	err := w.walkContents(st, fl)
+	st.stack = st.stack[:len(st.stack)-1]
+	if err != nil && !errors.Is(err, SkipFolderErr) {
+		return err
+	}
+	if w.LeaveFolder != nil {
+		st.node = fl
+		if err = w.LeaveFolder(st, fl); err != nil && !errors.Is(err, SkipFolderErr) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Walker) walkContents(st *WalkState, fl *MyFolder) error {
+	for _, fi := range fl.files {
+		if err := st.ctx.Err(); err != nil {
+			return err
+		}
+		if w.VisitFile != nil {
+			st.node = fi
+			if err := w.VisitFile(st, fi); err != nil {
+				return err
+			}
+		}
+	}
+	for _, d := range fl.dirs {
+		if err := w.walkFolder(st, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
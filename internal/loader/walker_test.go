@@ -0,0 +1,188 @@
+package loader_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// tracer records a walk as lines of "depth event path".
+type tracer struct {
+	lines []string
+	// act, if not nil, decides what a callback returns.
+	act func(event, path string) error
+}
+
+func (tr *tracer) record(st *WalkState, event string) error {
+	tr.lines = append(tr.lines, fmt.Sprintf("%d %s %s", st.Depth(), event, st.Path()))
+	if tr.act == nil {
+		return nil
+	}
+	return tr.act(event, st.Path())
+}
+
+func (tr *tracer) walker() *Walker {
+	return &Walker{
+		EnterFolder: func(st *WalkState, _ *MyFolder) error { return tr.record(st, "enter") },
+		LeaveFolder: func(st *WalkState, _ *MyFolder) error { return tr.record(st, "leave") },
+		VisitFile:   func(st *WalkState, _ *MyFile) error { return tr.record(st, "file") },
+	}
+}
+
+func TestWalk(t *testing.T) {
+	tr := &tracer{}
+	assert.NoError(t, tr.walker().Walk(context.Background(), makeBenelux()))
+	assert.Equal(t, []string{
+		"0 enter ",
+		"1 file README.md",
+		"1 file history.md",
+		"1 enter belgium",
+		"2 file belgium/tintin.md",
+		"2 file belgium/beer.md",
+		"2 enter belgium/antwerp",
+		"3 file belgium/antwerp/README.md",
+		"3 file belgium/antwerp/diamonds.md",
+		"3 file belgium/antwerp/rubens.md",
+		"2 leave belgium/antwerp",
+		"1 leave belgium",
+		"1 enter netherlands",
+		"2 file netherlands/README.md",
+		"2 file netherlands/drenthe.md",
+		"1 leave netherlands",
+		"0 leave ",
+	}, tr.lines)
+}
+
+func TestWalkStack(t *testing.T) {
+	var stacks []string
+	err := (&Walker{
+		VisitFile: func(st *WalkState, fi *MyFile) error {
+			var names []string
+			for _, fl := range st.Stack() {
+				names = append(names, fl.Name())
+			}
+			stacks = append(stacks, strings.Join(names, ","))
+			return nil
+		},
+	}).Walk(context.Background(), makeBenelux().Lookup("belgium").(*MyFolder))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"belgium", "belgium", "belgium,antwerp", "belgium,antwerp", "belgium,antwerp",
+	}, stacks)
+}
+
+func TestWalkEarlyExit(t *testing.T) {
+	boom := errors.New("boom")
+	for n, tc := range map[string]struct {
+		act  func(event, path string) error
+		err  error
+		want []string
+	}{
+		"skipEnteredFolder": {
+			act: func(event, path string) error {
+				if event == "enter" && path == "belgium" {
+					return SkipFolderErr
+				}
+				return nil
+			},
+			want: []string{
+				"0 enter ",
+				"1 file README.md",
+				"1 file history.md",
+				"1 enter belgium",
+				"1 enter netherlands",
+				"2 file netherlands/README.md",
+				"2 file netherlands/drenthe.md",
+				"1 leave netherlands",
+				"0 leave ",
+			},
+		},
+		"skipRestOfFolderFromFile": {
+			act: func(event, path string) error {
+				if path == "belgium/tintin.md" {
+					return SkipFolderErr
+				}
+				return nil
+			},
+			want: []string{
+				"0 enter ",
+				"1 file README.md",
+				"1 file history.md",
+				"1 enter belgium",
+				"2 file belgium/tintin.md",
+				"1 leave belgium",
+				"1 enter netherlands",
+				"2 file netherlands/README.md",
+				"2 file netherlands/drenthe.md",
+				"1 leave netherlands",
+				"0 leave ",
+			},
+		},
+		"skipAll": {
+			act: func(event, path string) error {
+				if path == "belgium/beer.md" {
+					return SkipAllErr
+				}
+				return nil
+			},
+			want: []string{
+				"0 enter ",
+				"1 file README.md",
+				"1 file history.md",
+				"1 enter belgium",
+				"2 file belgium/tintin.md",
+				"2 file belgium/beer.md",
+			},
+		},
+		"error": {
+			act: func(event, path string) error {
+				if event == "leave" && path == "belgium/antwerp" {
+					return boom
+				}
+				return nil
+			},
+			err: boom,
+			want: []string{
+				"0 enter ",
+				"1 file README.md",
+				"1 file history.md",
+				"1 enter belgium",
+				"2 file belgium/tintin.md",
+				"2 file belgium/beer.md",
+				"2 enter belgium/antwerp",
+				"3 file belgium/antwerp/README.md",
+				"3 file belgium/antwerp/diamonds.md",
+				"3 file belgium/antwerp/rubens.md",
+				"2 leave belgium/antwerp",
+			},
+		},
+	} {
+		t.Run(n, func(t *testing.T) {
+			tr := &tracer{act: tc.act}
+			err := tr.walker().Walk(context.Background(), makeBenelux())
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.want, tr.lines)
+		})
+	}
+}
+
+func TestWalkCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tr := &tracer{act: func(event, path string) error {
+		if path == "history.md" {
+			cancel()
+		}
+		return nil
+	}}
+	err := tr.walker().Walk(ctx, makeBenelux())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"0 enter ", "1 file README.md", "1 file history.md"}, tr.lines)
+}
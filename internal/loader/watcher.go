@@ -0,0 +1,417 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// WatchEventKind says how a watched tree changed.
+type WatchEventKind int
+
+const (
+	WatchFileAdded WatchEventKind = iota
+	WatchFileRemoved
+	WatchFileModified
+	WatchFolderAdded
+	WatchFolderRemoved
+	// WatchReordered means a folder's files or folders changed order,
+	// because its ordering file (OrderingFileName) changed.
+	WatchReordered
+)
+
+func (k WatchEventKind) String() string {
+	switch k {
+	case WatchFileAdded:
+		return "file added"
+	case WatchFileRemoved:
+		return "file removed"
+	case WatchFileModified:
+		return "file modified"
+	case WatchFolderAdded:
+		return "folder added"
+	case WatchFolderRemoved:
+		return "folder removed"
+	case WatchReordered:
+		return "reordered"
+	default:
+		return "unknown"
+	}
+}
+
+// WatchEvent describes a change made to a watched tree.
+type WatchEvent struct {
+	Kind WatchEventKind
+	// Path is slash separated, and relative to the watched folder,
+	// e.g. "belgium/antwerp/diamonds.md".  It's empty for the folder itself.
+	Path string
+	// Node is the file or folder that changed.  A removed node
+	// is no longer in the tree.  For WatchReordered, it's the folder.
+	Node MyTreeNode
+}
+
+// Watcher keeps a tree loaded from a folder up to date as files
+// and folders are added, removed and changed on disk.
+//
+// Changes are applied to the tree in place, one at a time, and then
+// passed as events to subscribers.  Use Read to look at the tree safely
+// while the watcher is running.
+type Watcher struct {
+	ldr  *FsLoader
+	dir  string
+	fsw  *fsnotify.Watcher
+	done chan struct{}
+
+	// mu guards the tree.
+	mu   sync.Mutex
+	tree *MyFolder
+
+	// subMu guards the subscribers.
+	subMu    sync.Mutex
+	onChange []func(WatchEvent)
+	onError  []func(error)
+}
+
+// Watch loads the folder at the path (see LoadFolder) and watches it for
+// changes, until the returned watcher is closed.  Ordering files, the
+// loader's filters and its MaxDepth are honored as changes arrive, just
+// as when loading.  Unlike LoadFolder, the path must be a folder.
+//
+// The loader must use the real file system (afero.NewOsFs), since
+// the operating system is asked to report changes.
+func (fsl *FsLoader) Watch(path string) (*Watcher, error) {
+	info, err := fsl.fs.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%q isn't a folder; watch the folder holding it", path)
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		ldr:  fsl,
+		dir:  filepath.Clean(path),
+		fsw:  fsw,
+		done: make(chan struct{}),
+	}
+	// Watch before loading, so no change is missed.
+	if err = w.addWatches(w.dir); err != nil {
+		_ = fsw.Close()
+		return nil, err
+	}
+	if w.tree, err = fsl.LoadFolder(w.dir); err != nil {
+		_ = fsw.Close()
+		return nil, err
+	}
+	if w.tree == nil {
+		w.tree = NewFolder(fsl.displayName(w.dir))
+		w.tree.diskPath = w.dir
+	}
+	go w.run()
+	return w, nil
+}
+
+// Read calls the function with the tree, while no changes are
+// being applied to it.  The function mustn't keep the tree.
+func (w *Watcher) Read(f func(*MyFolder)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	f(w.tree)
+}
+
+// Subscribe arranges for the function to be called with each change,
+// after the change is made.  Calls are made one at a time, from the
+// watcher's goroutine, so a slow function delays later changes.
+func (w *Watcher) Subscribe(f func(WatchEvent)) {
+	w.subMu.Lock()
+	defer w.subMu.Unlock()
+	w.onChange = append(w.onChange, f)
+}
+
+// OnError arranges for the function to be called with problems
+// that keep a change from being applied.
+func (w *Watcher) OnError(f func(error)) {
+	w.subMu.Lock()
+	defer w.subMu.Unlock()
+	w.onError = append(w.onError, f)
+}
+
+// Close stops watching.  The tree is left as it is.
+func (w *Watcher) Close() error {
+	err := w.fsw.Close()
+	<-w.done
+	return err
+}
+
+// addWatches watches the folder and all the allowed folders below it,
+// down to the loader's MaxDepth below the watched folder.
+func (w *Watcher) addWatches(dir string) error {
+	return w.ldr.fs.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			// Removed while walking; its own event will follow.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p != dir && w.ldr.IsAllowedFolder(info) != nil {
+			return filepath.SkipDir
+		}
+		if rel, err := filepath.Rel(w.dir, p); err == nil && w.tooDeep(rel) {
+			return filepath.SkipDir
+		}
+		return w.fsw.Add(p)
+	})
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handle(ev.Name)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			w.publishError(err)
+		}
+	}
+}
+
+func (w *Watcher) publish(events []WatchEvent) {
+	w.subMu.Lock()
+	subs := slices.Clone(w.onChange)
+	w.subMu.Unlock()
+	for _, ev := range events {
+		for _, f := range subs {
+			f(ev)
+		}
+	}
+}
+
+func (w *Watcher) publishError(err error) {
+	w.subMu.Lock()
+	subs := slices.Clone(w.onError)
+	w.subMu.Unlock()
+	for _, f := range subs {
+		f(err)
+	}
+}
+
+// handle brings the tree up to date with the file or folder at the path.
+// Rather than trusting the kind of event reported, it looks at what's
+// on disk now, since events can be coalesced or arrive out of order.
+func (w *Watcher) handle(diskPath string) {
+	rel, err := filepath.Rel(w.dir, diskPath)
+	if err != nil || rel == currentDir || strings.HasPrefix(rel, upDir) {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	info, err := w.ldr.fs.Stat(diskPath)
+	w.mu.Lock()
+	var events []WatchEvent
+	switch {
+	case os.IsNotExist(err):
+		events, err = w.removed(rel), nil
+	case err != nil:
+		// Leave the tree alone, and report the error.
+	case info.IsDir():
+		events, err = w.folderAppeared(diskPath, rel, info)
+	case IsOrderingFile(info):
+		events, err = w.reorder(path.Dir(rel))
+	default:
+		events, err = w.fileChanged(diskPath, rel, info)
+	}
+	w.mu.Unlock()
+	if err != nil {
+		w.publishError(err)
+	}
+	w.publish(events)
+}
+
+// lookup is Lookup, without the ".md" default, so that a folder "x"
+// isn't mistaken for a file "x.md".
+func (w *Watcher) lookup(rel string) MyTreeNode {
+	n := w.tree.Lookup(rel)
+	if n == nil || n == MyTreeNode(w.tree) || n.Name() == path.Base(rel) {
+		return n
+	}
+	return nil
+}
+
+// removed handles a file or folder that's gone from disk.
+func (w *Watcher) removed(rel string) []WatchEvent {
+	if path.Base(rel) == OrderingFileName {
+		events, _ := w.reorder(path.Dir(rel))
+		return events
+	}
+	n := w.lookup(rel)
+	if n == nil {
+		return nil
+	}
+	parent := parentFolder(n)
+	detach(n)
+	var events []WatchEvent
+	if fi, ok := n.(*MyFile); ok {
+		events = append(events, WatchEvent{Kind: WatchFileRemoved, Path: rel, Node: fi})
+	} else {
+		events = append(events, WatchEvent{Kind: WatchFolderRemoved, Path: rel, Node: n})
+	}
+	// Drop folders left empty, as LoadFolder would.
+	for parent != w.tree && parent.IsEmpty() {
+		rel = path.Dir(rel)
+		up := parentFolder(parent)
+		detach(parent)
+		events = append(events, WatchEvent{Kind: WatchFolderRemoved, Path: rel, Node: parent})
+		parent = up
+	}
+	return events
+}
+
+// folderAppeared handles a new folder, which might already hold files.
+func (w *Watcher) folderAppeared(
+	diskPath, rel string, info os.FileInfo) ([]WatchEvent, error) {
+	if w.ldr.IsAllowedFolder(info) != nil || w.lookup(rel) != nil {
+		return nil, nil
+	}
+	if err := w.addWatches(diskPath); err != nil {
+		return nil, err
+	}
+	if w.tooDeep(rel) {
+		return nil, nil
+	}
+	fld, err := w.ldr.loadFolder(&loadState{}, diskPath, relDepth(rel))
+	if err != nil || fld == nil {
+		return nil, err
+	}
+	fld.name = info.Name()
+	parent, events := w.ensureFolder(path.Dir(rel))
+	parent.AddFolderObject(fld)
+	if err = w.sortFolder(parent, path.Dir(rel)); err != nil {
+		return nil, err
+	}
+	return append(events, WatchEvent{Kind: WatchFolderAdded, Path: rel, Node: fld}), nil
+}
+
+// fileChanged handles a file that's new or has new content.
+func (w *Watcher) fileChanged(
+	diskPath, rel string, info os.FileInfo) ([]WatchEvent, error) {
+	if w.ldr.IsAllowedFile(info) != nil || w.tooDeep(path.Dir(rel)) {
+		return nil, nil
+	}
+	fresh, err := w.ldr.loadFile(diskPath, info.Name(), info)
+	if err != nil {
+		return nil, err
+	}
+	if fi, ok := w.lookup(rel).(*MyFile); ok {
+		if bytes.Equal(fi.content, fresh.content) && fi.norm == fresh.norm {
+			return nil, nil
+		}
+		fi.content, fi.norm = fresh.content, fresh.norm
+		return []WatchEvent{{Kind: WatchFileModified, Path: rel, Node: fi}}, nil
+	}
+	parent, events := w.ensureFolder(path.Dir(rel))
+	parent.AddFileObject(fresh)
+	if err = w.sortFolder(parent, path.Dir(rel)); err != nil {
+		return nil, err
+	}
+	return append(events, WatchEvent{Kind: WatchFileAdded, Path: rel, Node: fresh}), nil
+}
+
+// relDepth returns how far below the watched folder the folder at the
+// relative path is, as counted by LoadFolder.
+func relDepth(rel string) int {
+	rel = filepath.ToSlash(rel)
+	if rel == currentDir {
+		return 0
+	}
+	return strings.Count(rel, "/") + 1
+}
+
+// tooDeep is true if the folder at the relative path is deeper
+// than LoadFolder would go.
+func (w *Watcher) tooDeep(rel string) bool {
+	return w.ldr.MaxDepth > 0 && relDepth(rel) > w.ldr.MaxDepth
+}
+
+// ensureFolder returns the folder at the slash separated path relative
+// to the watched folder, adding folders to the tree as needed.
+// Folders are only missing from the tree if they were empty.
+func (w *Watcher) ensureFolder(rel string) (*MyFolder, []WatchEvent) {
+	fl := w.tree
+	var (
+		events []WatchEvent
+		sofar  string
+	)
+	if rel == currentDir {
+		return fl, nil
+	}
+	for _, name := range strings.Split(rel, "/") {
+		sofar = path.Join(sofar, name)
+		next, ok := w.lookup(sofar).(*MyFolder)
+		if !ok {
+			next = NewFolder(name)
+			fl.AddFolderObject(next)
+			// The ordering of fl is fixed by the caller, or by the
+			// next iteration, where it's the parent of the folder added.
+			_ = w.sortFolder(fl, path.Dir(sofar))
+			events = append(events, WatchEvent{Kind: WatchFolderAdded, Path: sofar, Node: next})
+		}
+		fl = next
+	}
+	return fl, events
+}
+
+// reorder reorders the folder at the slash separated path relative
+// to the watched folder, e.g. after its ordering file changed.
+func (w *Watcher) reorder(rel string) ([]WatchEvent, error) {
+	fl, ok := w.lookup(rel).(*MyFolder)
+	if !ok {
+		return nil, nil
+	}
+	files, dirs := slices.Clone(fl.files), slices.Clone(fl.dirs)
+	if err := w.sortFolder(fl, rel); err != nil {
+		return nil, err
+	}
+	if slices.Equal(files, fl.files) && slices.Equal(dirs, fl.dirs) {
+		return nil, nil
+	}
+	if rel == currentDir {
+		rel = ""
+	}
+	return []WatchEvent{{Kind: WatchReordered, Path: rel, Node: fl}}, nil
+}
+
+// sortFolder puts the folder's files and folders in the order that
+// LoadFolder would, using the ordering file on disk, if any.
+func (w *Watcher) sortFolder(fl *MyFolder, rel string) error {
+	var ordering []string
+	p := filepath.Join(w.dir, filepath.FromSlash(rel), OrderingFileName)
+	if _, err := w.ldr.fs.Stat(p); err == nil {
+		if ordering, err = LoadOrderFile(w.ldr.fs, p); err != nil {
+			return fmt.Errorf("unable to reorder %q; %w", rel, err)
+		}
+	}
+	slices.SortFunc(fl.files, func(a, b *MyFile) int { return strings.Compare(a.name, b.name) })
+	slices.SortFunc(fl.dirs, func(a, b *MyFolder) int { return strings.Compare(a.name, b.name) })
+	fl.files = ReorderFiles(fl.files, ordering)
+	fl.dirs = ReorderFolders(fl.dirs, ordering)
+	fl.dropIndex()
+	return nil
+}
@@ -0,0 +1,139 @@
+package loader_test
+
+import (
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// watchEvents returns a channel receiving the watcher's events,
+// rendered as "kind path".
+func watchEvents(w *Watcher) chan string {
+	ch := make(chan string, 100)
+	w.Subscribe(func(ev WatchEvent) { ch <- ev.Kind.String() + " " + ev.Path })
+	return ch
+}
+
+func expectEvents(t *testing.T, ch chan string, want ...string) {
+	t.Helper()
+	var got []string
+	for len(got) < len(want) {
+		select {
+		case ev := <-ch:
+			got = append(got, ev)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got events %v, want %v", got, want)
+		}
+	}
+	assert.ElementsMatch(t, want, got)
+}
+
+func lessonNames(w *Watcher) (result []string) {
+	w.Read(func(fld *MyFolder) {
+		for _, fi := range fld.Lessons() {
+			var p string
+			for _, a := range fld.Ancestors(fi)[1:] {
+				p += a.Name() + "/"
+			}
+			result = append(result, p+fi.Name())
+		}
+	})
+	return
+}
+
+func TestWatcher(t *testing.T) {
+	dir, tmp := t.TempDir(), t.TempDir()
+	// write writes files in one step, by renaming, so that
+	// each write is a single change.
+	write := func(p, c string) {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		f := filepath.Join(tmp, "f")
+		assert.NoError(t, os.WriteFile(f, []byte(c), RW))
+		assert.NoError(t, os.Rename(f, p))
+	}
+	write("a.md", "# a")
+	write("b.md", "# b")
+	write("sub/c.md", "# c")
+
+	w, err := NewFsLoader(afero.NewOsFs()).Watch(dir)
+	assert.NoError(t, err)
+	defer func() { assert.NoError(t, w.Close()) }()
+	w.OnError(func(err error) { t.Error(err) })
+	ch := watchEvents(w)
+	assert.Equal(t, []string{"a.md", "b.md", "sub/c.md"}, lessonNames(w))
+
+	write("b.md", "# b, again")
+	expectEvents(t, ch, "file modified b.md")
+	w.Read(func(fld *MyFolder) {
+		assert.Equal(t, "# b, again", string(fld.Lookup("b").(*MyFile).C()))
+	})
+
+	write("sub/aa.md", "# aa")
+	expectEvents(t, ch, "file added sub/aa.md")
+	assert.Equal(t, []string{"a.md", "b.md", "sub/aa.md", "sub/c.md"}, lessonNames(w))
+
+	write(OrderingFileName, "b.md\nsub\n")
+	expectEvents(t, ch, "reordered ")
+	assert.Equal(t, []string{"b.md", "a.md", "sub/aa.md", "sub/c.md"}, lessonNames(w))
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "sub", "aa.md")))
+	expectEvents(t, ch, "file removed sub/aa.md")
+	assert.NoError(t, os.Remove(filepath.Join(dir, "sub", "c.md")))
+	expectEvents(t, ch, "file removed sub/c.md", "folder removed sub")
+	assert.Equal(t, []string{"b.md", "a.md"}, lessonNames(w))
+
+	// A folder arriving with files in it, as when moved in from elsewhere.
+	other := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(other, "d.md"), []byte("# d"), RW))
+	assert.NoError(t, os.Rename(other, filepath.Join(dir, "new")))
+	expectEvents(t, ch, "folder added new")
+	assert.Equal(t, []string{"b.md", "a.md", "new/d.md"}, lessonNames(w))
+
+	// Disallowed files are ignored.
+	write("notes.txt", "hey")
+	write("new/e.md", "# e")
+	expectEvents(t, ch, "file added new/e.md")
+	assert.Equal(t, []string{"b.md", "a.md", "new/d.md", "new/e.md"}, lessonNames(w))
+}
+
+func TestWatcherMaxDepth(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"a.md", "sub/c.md", "sub/deep/x.md"} {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.NoError(t, os.WriteFile(p, []byte("# x"), RW))
+	}
+	ldr := NewFsLoader(afero.NewOsFs())
+	ldr.MaxDepth = 1
+	w, err := ldr.Watch(dir)
+	assert.NoError(t, err)
+	defer func() { assert.NoError(t, w.Close()) }()
+	w.OnError(func(err error) { t.Error(err) })
+	ch := watchEvents(w)
+	assert.Equal(t, []string{"a.md", "sub/c.md"}, lessonNames(w))
+
+	// Changes too deep to have been loaded are ignored.
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "deep", "y.md"), []byte("# y"), RW))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub", "deeper"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "deeper", "z.md"), []byte("# z"), RW))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "d.md"), []byte("# d"), RW))
+	expectEvents(t, ch, "file added sub/d.md")
+	select {
+	case ev := <-ch:
+		t.Errorf("unexpected event %q", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, []string{"a.md", "sub/c.md", "sub/d.md"}, lessonNames(w))
+}
+
+func TestWatchFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "a.md")
+	assert.NoError(t, os.WriteFile(p, []byte("# a"), RW))
+	_, err := NewFsLoader(afero.NewOsFs()).Watch(p)
+	assert.ErrorContains(t, err, "isn't a folder")
+}
@@ -0,0 +1,326 @@
+package loader
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// OriginKind says what kind of thing a workspace root was loaded from.
+type OriginKind int
+
+const (
+	// OriginLocal is a folder or file on the loader's file system.
+	OriginLocal OriginKind = iota
+	// OriginArchive is a zip or tar file on the loader's file system.
+	OriginArchive
+	// OriginGit is a cloned git repository.
+	OriginGit
+	// OriginGitRef is a ref in a local git repository.
+	OriginGitRef
+	// OriginStdin is standard input.
+	OriginStdin
+	// OriginSnapshot is a snapshot file on the loader's file system.
+	OriginSnapshot
+)
+
+func (k OriginKind) String() string {
+	switch k {
+	case OriginLocal:
+		return "local"
+	case OriginArchive:
+		return "archive"
+	case OriginGit:
+		return "git"
+	case OriginGitRef:
+		return "gitRef"
+	case OriginStdin:
+		return "stdin"
+	case OriginSnapshot:
+		return "snapshot"
+	default:
+		return "unknown"
+	}
+}
+
+// Origin describes where a workspace root came from.
+type Origin struct {
+	Kind OriginKind
+	// Arg is the argument the root was loaded from.
+	Arg string
+	// Location is the absolute path to a local folder, file or archive,
+	// the url of a cloned repository, or the path to a local repository.
+	Location string
+	// Ref is the branch, tag or commit of a repository, if any.
+	Ref string
+	// Path is the path loaded from a repository, if any.
+	Path string
+}
+
+// covers is true if loading o loads everything loaded by other.
+func (o *Origin) covers(other *Origin) bool {
+	if o.Kind != other.Kind {
+		return false
+	}
+	switch o.Kind {
+	case OriginLocal:
+		return isWithin(other.Location, o.Location)
+	case OriginArchive, OriginSnapshot:
+		return o.Location == other.Location
+	case OriginStdin:
+		// Standard input can only be read once.
+		return true
+	case OriginGit, OriginGitRef:
+		return o.Location == other.Location &&
+			o.Ref == other.Ref && isWithin(other.Path, o.Path)
+	default:
+		return false
+	}
+}
+
+// isWithin is true if the path is the folder or below it.
+// The empty folder holds everything.
+func isWithin(path, folder string) bool {
+	return folder == "" || path == folder ||
+		strings.HasPrefix(path, strings.TrimSuffix(folder, rootSlash)+rootSlash)
+}
+
+// WorkspaceRoot is a tree loaded into a Workspace.
+type WorkspaceRoot struct {
+	// Prefix uniquely names the root in its workspace.  It's the name
+	// of the root folder, so it begins the FullName of everything in
+	// the root, and thus the workspace's routes.
+	Prefix string
+	Origin Origin
+	Folder *MyFolder
+}
+
+// Workspace holds trees loaded from any number of arguments, e.g.
+// local folders, archives and git repositories.
+//
+// Each tree becomes a root, named by a prefix derived from its origin.
+// Prefixes are unique, and depend only on the arguments and the order
+// in which they were added, so they're stable across runs.
+//
+// Arguments that overlap an existing root are not loaded twice.
+type Workspace struct {
+	ldr    *FsLoader
+	roots  []*WorkspaceRoot
+	folder *MyFolder
+}
+
+// NewWorkspace returns an empty workspace that loads with the given loader.
+func NewWorkspace(ldr *FsLoader) *Workspace {
+	return &Workspace{ldr: ldr}
+}
+
+// Roots returns the workspace roots in the order added.
+func (ws *Workspace) Roots() []*WorkspaceRoot {
+	return ws.roots
+}
+
+// Add loads the argument (anything LoadTree accepts) as a new root.
+//
+// If an existing root already holds everything the argument would load,
+// that root is returned and nothing is loaded.  If the new root holds
+// existing roots, they're dropped in favor of it.  If nothing passes the
+// loader's filters, no root is added and the function returns nil.
+func (ws *Workspace) Add(arg string) (*WorkspaceRoot, error) {
+	o, err := ws.originOf(arg)
+	if err != nil {
+		return nil, err
+	}
+	return ws.add(o, func() (*MyFolder, error) {
+		return ws.ldr.LoadTree(arg)
+	})
+}
+
+// AddGitRef loads a path at a ref in a local git repository as a new root.
+// See Add and FsLoader.LoadGitRef.
+func (ws *Workspace) AddGitRef(repoDir, ref, path string) (*WorkspaceRoot, error) {
+	loc, err := filepath.Abs(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	o := &Origin{
+		Kind:     OriginGitRef,
+		Arg:      repoDir + refMarker + ref + rootSlash + path,
+		Location: loc,
+		Ref:      ref,
+		Path:     cleanRepoPath(path),
+	}
+	return ws.add(o, func() (*MyFolder, error) {
+		return ws.ldr.LoadGitRef(repoDir, ref, path)
+	})
+}
+
+func (ws *Workspace) add(
+	o *Origin, load func() (*MyFolder, error)) (*WorkspaceRoot, error) {
+	for _, r := range ws.roots {
+		if r.Origin.covers(o) {
+			return r, nil
+		}
+	}
+	fld, err := load()
+	if err != nil || fld == nil {
+		return nil, err
+	}
+	var kept []*WorkspaceRoot
+	for _, r := range ws.roots {
+		if !o.covers(&r.Origin) {
+			kept = append(kept, r)
+		}
+	}
+	ws.roots = kept
+	r := &WorkspaceRoot{Prefix: ws.uniquePrefix(prefixOf(o)), Origin: *o, Folder: fld}
+	fld.name = r.Prefix
+	ws.roots = append(ws.roots, r)
+	ws.folder = nil
+	return r, nil
+}
+
+// originOf figures out where the argument to Add would load from.
+func (ws *Workspace) originOf(arg string) (*Origin, error) {
+	if arg == StdinArg {
+		return &Origin{Kind: OriginStdin, Arg: arg}, nil
+	}
+	if smellsLikeGitCloneArg(arg) {
+		rs, err := parseRepoSpec(arg)
+		if err != nil {
+			return nil, err
+		}
+		return &Origin{
+			Kind:     OriginGit,
+			Arg:      arg,
+			Location: rs.url(),
+			Ref:      rs.ref,
+			Path:     cleanRepoPath(rs.path),
+		}, nil
+	}
+	if repoDir, ref, p, ok := ws.ldr.splitGitRefArg(arg); ok {
+		loc, err := filepath.Abs(repoDir)
+		if err != nil {
+			return nil, err
+		}
+		return &Origin{
+			Kind:     OriginGitRef,
+			Arg:      arg,
+			Location: loc,
+			Ref:      ref,
+			Path:     cleanRepoPath(p),
+		}, nil
+	}
+	loc, err := filepath.Abs(arg)
+	if err != nil {
+		return nil, err
+	}
+	k := OriginLocal
+	if _, ok := SnapshotFormatOf(arg); ok {
+		k = OriginSnapshot
+	} else if smellsLikeArchive(arg) {
+		k = OriginArchive
+	}
+	return &Origin{Kind: k, Arg: arg, Location: loc}, nil
+}
+
+// cleanRepoPath cleans a path in a repository, using "" for the top.
+func cleanRepoPath(p string) string {
+	p = strings.Trim(filepath.Clean(p), rootSlash)
+	if p == currentDir {
+		return ""
+	}
+	return p
+}
+
+// prefixOf returns a short, readable name for the origin.
+func prefixOf(o *Origin) string {
+	var n string
+	switch o.Kind {
+	case OriginStdin:
+		n = "stdin"
+	case OriginGit, OriginGitRef:
+		n = filepath.Base(strings.TrimSuffix(strings.TrimSuffix(o.Location, dotGit), rootSlash))
+	case OriginArchive, OriginSnapshot:
+		n = filepath.Base(o.Location)
+		for _, ext := range []string{
+			extTarGz, extTgz, extZip, extTar, SnapshotExtJSON, SnapshotExtGob} {
+			if strings.HasSuffix(strings.ToLower(n), ext) {
+				n = n[:len(n)-len(ext)]
+				break
+			}
+		}
+	default:
+		n = strings.TrimSuffix(filepath.Base(o.Location), ".md")
+	}
+	n = strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' ||
+			('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '-'
+	}, n)
+	if strings.Trim(n, ".") == "" {
+		return "root"
+	}
+	return n
+}
+
+// uniquePrefix returns the prefix, or if it's taken,
+// the prefix with the smallest numeric suffix not taken.
+func (ws *Workspace) uniquePrefix(p string) string {
+	taken := make(map[string]bool)
+	for _, r := range ws.roots {
+		taken[r.Prefix] = true
+	}
+	result := p
+	for i := 2; taken[result]; i++ {
+		result = fmt.Sprintf("%s-%d", p, i)
+	}
+	return result
+}
+
+// Folder returns one unnamed folder holding all the roots' folders.
+// A node's FullName in this folder starts with its root's prefix.
+func (ws *Workspace) Folder() *MyFolder {
+	if ws.folder == nil {
+		ws.folder = NewFolder("")
+		for _, r := range ws.roots {
+			ws.folder.AddFolderObject(r.Folder)
+		}
+	}
+	return ws.folder
+}
+
+// RootOf returns the root holding the node, or nil.
+func (ws *Workspace) RootOf(n MyTreeNode) *WorkspaceRoot {
+	for _, r := range ws.roots {
+		for p := n; p != nil; p = p.Parent() {
+			if fl, ok := p.(*MyFolder); ok && fl == r.Folder {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// Route returns a slash separated path to the node that's unique in the
+// workspace and starts with the prefix of the node's root, e.g.
+// "mdrip/data/example.md".  Use it to make web routes and block IDs.
+// It returns an empty string if the node isn't in the workspace.
+func (ws *Workspace) Route(n MyTreeNode) string {
+	r := ws.RootOf(n)
+	if r == nil {
+		return ""
+	}
+	var parts []string
+	for p := n; p != nil; p = p.Parent() {
+		parts = append(parts, p.Name())
+		if fl, ok := p.(*MyFolder); ok && fl == r.Folder {
+			break
+		}
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, "/")
+}
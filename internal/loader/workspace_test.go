@@ -0,0 +1,103 @@
+package loader_test
+
+import (
+	"bytes"
+	. "github.com/monopole/mdparse/internal/loader"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWorkspace(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	makeLargeAbsFs(t, fs)
+	assert.NoError(t, afero.WriteFile(fs, "/other/jjj/f00.md", md[0].C(), RW))
+	assert.NoError(t, afero.WriteFile(fs, "/bundle.tar.gz", makeTarGz(t), RW))
+	ws := NewWorkspace(NewFsLoader(fs))
+
+	add := func(arg string) *WorkspaceRoot {
+		t.Helper()
+		r, err := ws.Add(arg)
+		assert.NoError(t, err)
+		return r
+	}
+	prefixes := func() (result []string) {
+		for _, r := range ws.Roots() {
+			result = append(result, r.Prefix)
+		}
+		return
+	}
+
+	ccc := add("/jjj/ccc")
+	assert.Equal(t, "ccc", ccc.Prefix)
+	assert.Equal(t, OriginLocal, ccc.Origin.Kind)
+	assert.Equal(t, "/jjj/ccc", ccc.Origin.Location)
+
+	// Adding a parent replaces the child.
+	jjj := add("/jjj")
+	assert.Equal(t, []string{"jjj"}, prefixes())
+
+	// Adding a child again changes nothing.
+	assert.Same(t, jjj, add("/jjj/aaa/f00.md"))
+	assert.Same(t, jjj, add("/jjj/"))
+
+	// Name clashes get a suffix.
+	other := add("/other/jjj")
+	assert.Equal(t, "jjj-2", other.Prefix)
+
+	f10 := add("/f10.md")
+	assert.Equal(t, "f10", f10.Prefix)
+
+	bundle := add("/bundle.tar.gz")
+	assert.Equal(t, OriginArchive, bundle.Origin.Kind)
+	assert.Equal(t, "bundle", bundle.Prefix)
+	assert.Same(t, bundle, add("/bundle.tar.gz"))
+
+	var snap bytes.Buffer
+	assert.NoError(t, NewFolder("x").AddFileObject(md[1]).WriteSnapshot(&snap, SnapshotGob))
+	assert.NoError(t, afero.WriteFile(fs, "/ci/built"+SnapshotExtGob, snap.Bytes(), RW))
+	built := add("/ci/built" + SnapshotExtGob)
+	assert.Equal(t, OriginSnapshot, built.Origin.Kind)
+	assert.Equal(t, "built", built.Prefix)
+
+	// Nothing to load, so no root.
+	assert.NoError(t, fs.MkdirAll("/empty", RWX))
+	assert.Nil(t, add("/empty"))
+
+	assert.Equal(t, []string{"jjj", "jjj-2", "f10", "bundle", "built"}, prefixes())
+
+	fld := ws.Folder()
+	assert.Equal(t, 5, fld.NumFolders())
+	for _, r := range ws.Roots() {
+		assert.Equal(t, r.Prefix, r.Folder.Name())
+		assert.Same(t, fld, r.Folder.Parent())
+	}
+
+	f := NewFsLoader(fs)
+	f05, err := f.LoadFolder("/jjj/bbb/f05.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "", ws.Route(f05))
+	assert.Nil(t, ws.RootOf(f05))
+
+	assert.Equal(t, "jjj", ws.Route(jjj.Folder))
+	var found []string
+	fld.Accept(&routeCollector{ws: ws, routes: &found})
+	assert.Contains(t, found, "jjj/bbb/f05.md")
+	assert.Contains(t, found, "jjj-2/f00.md")
+	assert.Contains(t, found, "f10/f10.md")
+	assert.Contains(t, found, "bundle/mmm/eee/f07.md")
+}
+
+type routeCollector struct {
+	ws     *Workspace
+	routes *[]string
+}
+
+func (v *routeCollector) VisitFile(fi *MyFile) {
+	*v.routes = append(*v.routes, v.ws.Route(fi))
+}
+
+func (v *routeCollector) VisitFolder(fl *MyFolder) {
+	fl.VisitFiles(v)
+	fl.VisitFolders(v)
+}
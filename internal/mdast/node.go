@@ -0,0 +1,175 @@
+// Package mdast describes markdown syntax trees the same way
+// whichever parser made them, so they can be printed and compared
+// when debugging, e.g. to see where a label comment landed.
+package mdast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Format is a way of writing a tree; see Node.Write.
+type Format int
+
+const (
+	// FormatText is an indented outline, one node per line.
+	FormatText Format = iota
+	FormatJSON
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatText:
+		return "text"
+	case FormatJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range []Format{FormatText, FormatJSON} {
+		if strings.EqualFold(s, f.String()) {
+			return f, nil
+		}
+	}
+	return FormatText, fmt.Errorf("unknown ast format %q; use text or json", s)
+}
+
+// Range locates a node's source.
+type Range struct {
+	// Start and Stop are byte offsets, with Stop excluded.
+	Start int `json:"start"`
+	Stop  int `json:"stop"`
+	// Line is the line holding Start, counting from one.
+	Line int `json:"line"`
+}
+
+// Node is a node in a markdown syntax tree.
+type Node struct {
+	// Kind is the parser's name for the kind of node, e.g. "Heading".
+	Kind string `json:"kind"`
+	// Range is nil if the source of the node isn't known.
+	Range *Range `json:"range,omitempty"`
+	// Attributes are the node's attributes, e.g. a heading's id.
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Props are other properties that depend on the kind of
+	// node, e.g. a heading's level or a link's destination.
+	Props map[string]string `json:"props,omitempty"`
+	// Text is the literal text of text and code nodes.
+	Text     string  `json:"text,omitempty"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+// SetProp sets a property, ignoring empty values.
+func (n *Node) SetProp(k, v string) {
+	if v == "" {
+		return
+	}
+	if n.Props == nil {
+		n.Props = make(map[string]string)
+	}
+	n.Props[k] = v
+}
+
+// SetAttribute sets an attribute.
+func (n *Node) SetAttribute(k, v string) {
+	if n.Attributes == nil {
+		n.Attributes = make(map[string]string)
+	}
+	n.Attributes[k] = v
+}
+
+// Finish completes a tree made from the source: nodes without a range
+// get one spanning their children's ranges, and lines are filled in.
+func (n *Node) Finish(src []byte) {
+	var starts []int
+	for i, c := range src {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	n.finish(starts)
+}
+
+// finish does Finish, given the offsets at which lines after the first start.
+func (n *Node) finish(starts []int) {
+	for _, c := range n.Children {
+		c.finish(starts)
+		if c.Range == nil {
+			continue
+		}
+		if n.Range == nil {
+			n.Range = &Range{Start: c.Range.Start, Stop: c.Range.Stop}
+			continue
+		}
+		n.Range.Start = min(n.Range.Start, c.Range.Start)
+		n.Range.Stop = max(n.Range.Stop, c.Range.Stop)
+	}
+	if n.Range != nil {
+		n.Range.Line = sort.SearchInts(starts, n.Range.Start+1) + 1
+	}
+}
+
+// Write writes the tree below the node in the given format.
+func (n *Node) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatText:
+		var b strings.Builder
+		n.writeText(&b, 0)
+		_, err := io.WriteString(w, b.String())
+		return err
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(n)
+	default:
+		return fmt.Errorf("unknown ast format %d", format)
+	}
+}
+
+// writeText writes lines like
+//
+//	Heading [2:9] line 1 level=1 {id="a-title"}
+//	  Text [2:9] line 1 "A title"
+//
+// for "# A title"; a range is what the parser reports,
+// which may leave out markup such as the "# ".
+func (n *Node) writeText(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(n.Kind)
+	if n.Range != nil {
+		fmt.Fprintf(b, " [%d:%d] line %d", n.Range.Start, n.Range.Stop, n.Range.Line)
+	}
+	for _, k := range sortedKeys(n.Props) {
+		fmt.Fprintf(b, " %s=%s", k, n.Props[k])
+	}
+	if len(n.Attributes) > 0 {
+		var attrs []string
+		for _, k := range sortedKeys(n.Attributes) {
+			attrs = append(attrs, fmt.Sprintf("%s=%q", k, n.Attributes[k]))
+		}
+		b.WriteString(" {" + strings.Join(attrs, " ") + "}")
+	}
+	if n.Text != "" {
+		fmt.Fprintf(b, " %q", n.Text)
+	}
+	b.WriteString("\n")
+	for _, c := range n.Children {
+		c.writeText(b, depth+1)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
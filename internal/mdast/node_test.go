@@ -0,0 +1,61 @@
+package mdast
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func makeTree() *Node {
+	n := &Node{Kind: "Document", Children: []*Node{
+		{Kind: "Heading", Children: []*Node{
+			{Kind: "Text", Range: &Range{Start: 2, Stop: 7}, Text: "title"},
+		}},
+		{Kind: "Paragraph", Children: []*Node{
+			{Kind: "Text", Range: &Range{Start: 9, Stop: 13}, Text: "more"},
+			{Kind: "Emphasis"},
+		}},
+	}}
+	n.Children[0].SetProp("level", "1")
+	n.Children[0].SetProp("empty", "")
+	n.Children[0].SetAttribute("id", "title")
+	return n
+}
+
+func TestFinish(t *testing.T) {
+	n := makeTree()
+	n.Finish([]byte("# title\n\nmore\n"))
+	assert.Equal(t, &Range{Start: 2, Stop: 13, Line: 1}, n.Range)
+	assert.Equal(t, &Range{Start: 2, Stop: 7, Line: 1}, n.Children[0].Range)
+	assert.Equal(t, &Range{Start: 9, Stop: 13, Line: 3}, n.Children[1].Range)
+	assert.Nil(t, n.Children[1].Children[1].Range)
+}
+
+func TestWrite(t *testing.T) {
+	n := makeTree()
+	n.Finish([]byte("# title\n\nmore\n"))
+	var b bytes.Buffer
+	assert.NoError(t, n.Write(&b, FormatText))
+	assert.Equal(t, `Document [2:13] line 1
+  Heading [2:7] line 1 level=1 {id="title"}
+    Text [2:7] line 1 "title"
+  Paragraph [9:13] line 3
+    Text [9:13] line 3 "more"
+    Emphasis
+`, b.String())
+
+	b.Reset()
+	assert.NoError(t, n.Write(&b, FormatJSON))
+	var got Node
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &got))
+	assert.Equal(t, n, &got)
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("JSON")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatJSON, f)
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+}
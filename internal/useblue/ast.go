@@ -0,0 +1,89 @@
+package useblue
+
+import (
+	"bytes"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/monopole/mdparse/internal/mdast"
+	"strconv"
+)
+
+// Ast parses the markdown with gomarkdown, and returns the syntax tree.
+//
+// Since gomarkdown doesn't record where nodes come from, ranges are
+// found by searching the source for the text of each node holding
+// text, in document order.  They cover that text, not the markup
+// around it; other nodes span the nodes they hold.
+func Ast(c []byte) *mdast.Node {
+	loc := &locator{src: c}
+	result := loc.node(newParser().Parse(c))
+	result.Finish(c)
+	return result
+}
+
+// locator converts nodes, remembering where the last text was found.
+type locator struct {
+	src []byte
+	at  int
+}
+
+func (loc *locator) node(n ast.Node) *mdast.Node {
+	result := &mdast.Node{Kind: nodeType(n)}
+	var attr *ast.Attribute
+	if c := n.AsContainer(); c != nil {
+		attr = c.Attribute
+	}
+	if l := n.AsLeaf(); l != nil {
+		attr = l.Attribute
+		if len(l.Literal) > 0 {
+			result.Text = string(l.Literal)
+			if i := bytes.Index(loc.src[loc.at:], l.Literal); i >= 0 {
+				start := loc.at + i
+				loc.at = start + len(l.Literal)
+				result.Range = &mdast.Range{Start: start, Stop: loc.at}
+			}
+		}
+	}
+	if attr != nil {
+		if len(attr.ID) > 0 {
+			result.SetAttribute("id", string(attr.ID))
+		}
+		if len(attr.Classes) > 0 {
+			result.SetAttribute("class", string(bytes.Join(attr.Classes, []byte(" "))))
+		}
+		for k, v := range attr.Attrs {
+			result.SetAttribute(k, string(v))
+		}
+	}
+	switch x := n.(type) {
+	case *ast.Heading:
+		result.SetProp("level", strconv.Itoa(x.Level))
+		result.SetProp("headingID", x.HeadingID)
+	case *ast.CodeBlock:
+		result.SetProp("info", string(x.Info))
+		result.SetProp("fenced", strconv.FormatBool(x.IsFenced))
+	case *ast.List:
+		if x.ListFlags&ast.ListTypeOrdered != 0 {
+			result.SetProp("start", strconv.Itoa(x.Start))
+		}
+		if x.ListFlags&ast.ListTypeDefinition != 0 {
+			result.SetProp("definition", "true")
+		}
+		if x.BulletChar != 0 {
+			result.SetProp("marker", string(x.BulletChar))
+		}
+		if x.Delimiter != 0 {
+			result.SetProp("delimiter", string(x.Delimiter))
+		}
+		result.SetProp("tight", strconv.FormatBool(x.Tight))
+	case *ast.Link:
+		result.SetProp("destination", string(x.Destination))
+		result.SetProp("title", string(x.Title))
+	case *ast.Image:
+		result.SetProp("destination", string(x.Destination))
+		result.SetProp("title", string(x.Title))
+	}
+	for _, c := range n.GetChildren() {
+		result.Children = append(result.Children, loc.node(c))
+	}
+	return result
+}
@@ -0,0 +1,32 @@
+package useblue
+
+import (
+	"github.com/monopole/mdparse/internal/mdast"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAst(t *testing.T) {
+	src := []byte("{#top}\n# Title\n\nSee [this](http://x.com).\n\n```bash\necho hi\n```\n")
+	doc := Ast(src)
+	assert.Equal(t, "Document", doc.Kind)
+	if !assert.Len(t, doc.Children, 3) {
+		return
+	}
+	h := doc.Children[0]
+	assert.Equal(t, "Heading", h.Kind)
+	assert.Equal(t, "1", h.Props["level"])
+	assert.Equal(t, "top", h.Attributes["id"])
+	assert.Equal(t, &mdast.Range{Start: 9, Stop: 14, Line: 2}, h.Range)
+
+	link := doc.Children[1].Children[1]
+	assert.Equal(t, "Link", link.Kind)
+	assert.Equal(t, "http://x.com", link.Props["destination"])
+	assert.Equal(t, &mdast.Range{Start: 21, Stop: 25, Line: 4}, link.Range)
+
+	cb := doc.Children[2]
+	assert.Equal(t, "CodeBlock", cb.Kind)
+	assert.Equal(t, "bash", cb.Props["info"])
+	assert.Equal(t, "echo hi\n", cb.Text)
+	assert.Equal(t, &mdast.Range{Start: 51, Stop: 59, Line: 7}, cb.Range)
+}
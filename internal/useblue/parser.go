@@ -41,11 +41,17 @@ func (gm *gomark) Dump() {
 	ast.PrintWithPrefix(os.Stdout, gm.doc, "  ")
 }
 
-func NewMarker(doMyStuff bool) *gomark {
-	p := parser.NewWithExtensions(parser.CommonExtensions |
+// newParser returns a parser configured as used here.
+// Parsers can't be reused.
+func newParser() *parser.Parser {
+	return parser.NewWithExtensions(parser.CommonExtensions |
 		parser.AutoHeadingIDs |
 		parser.NoEmptyLineBeforeBlock |
 		parser.Attributes)
+}
+
+func NewMarker(doMyStuff bool) *gomark {
+	p := newParser()
 	if doMyStuff {
 		p.Opts.ParserHook = parserHook
 	}
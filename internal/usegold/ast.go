@@ -0,0 +1,95 @@
+package usegold
+
+import (
+	"fmt"
+	"github.com/monopole/mdparse/internal/mdast"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"strconv"
+	"strings"
+)
+
+// Ast parses the markdown as BlockAccumulator does, with the
+// default options changed by opts, and returns the syntax tree.
+func Ast(c []byte, opts ...Option) *mdast.Node {
+	doc := newMarkdown(NewOptions(opts...)).Parser().Parse(text.NewReader(c))
+	result := newAstNode(c, doc)
+	result.Finish(c)
+	return result
+}
+
+func newAstNode(src []byte, n ast.Node) *mdast.Node {
+	result := &mdast.Node{Kind: n.Kind().String()}
+	for _, a := range n.Attributes() {
+		v, ok := a.Value.([]byte)
+		if !ok {
+			v = []byte(fmt.Sprint(a.Value))
+		}
+		result.SetAttribute(string(a.Name), string(v))
+	}
+	if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+		result.Range = segmentsRange(n.Lines())
+	}
+	switch x := n.(type) {
+	case *ast.Heading:
+		result.SetProp("level", strconv.Itoa(x.Level))
+	case *ast.FencedCodeBlock:
+		if x.Info != nil {
+			result.SetProp("info", string(x.Info.Segment.Value(src)))
+		}
+		result.Text = linesText(src, x.Lines())
+	case *ast.CodeBlock:
+		result.Text = linesText(src, x.Lines())
+	case *ast.HTMLBlock:
+		result.Text = linesText(src, x.Lines())
+	case *ast.List:
+		result.SetProp("marker", string(x.Marker))
+		if x.IsOrdered() {
+			result.SetProp("start", strconv.Itoa(x.Start))
+		}
+		result.SetProp("tight", strconv.FormatBool(x.IsTight))
+	case *ast.Emphasis:
+		result.SetProp("level", strconv.Itoa(x.Level))
+	case *ast.Link:
+		result.SetProp("destination", string(x.Destination))
+		result.SetProp("title", string(x.Title))
+	case *ast.Image:
+		result.SetProp("destination", string(x.Destination))
+		result.SetProp("title", string(x.Title))
+	case *ast.AutoLink:
+		result.SetProp("url", string(x.URL(src)))
+	case *ast.Text:
+		result.Range = &mdast.Range{Start: x.Segment.Start, Stop: x.Segment.Stop}
+		result.Text = string(x.Segment.Value(src))
+		if x.SoftLineBreak() {
+			result.SetProp("softBreak", "true")
+		}
+		if x.HardLineBreak() {
+			result.SetProp("hardBreak", "true")
+		}
+	case *ast.String:
+		result.Text = string(x.Value)
+	case *ast.RawHTML:
+		if x.Segments.Len() > 0 {
+			result.Range = segmentsRange(x.Segments)
+		}
+		result.Text = linesText(src, x.Segments)
+	}
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		result.Children = append(result.Children, newAstNode(src, c))
+	}
+	return result
+}
+
+func segmentsRange(segs *text.Segments) *mdast.Range {
+	return &mdast.Range{Start: segs.At(0).Start, Stop: segs.At(segs.Len() - 1).Stop}
+}
+
+func linesText(src []byte, segs *text.Segments) string {
+	var b strings.Builder
+	for i := 0; i < segs.Len(); i++ {
+		s := segs.At(i)
+		b.Write(s.Value(src))
+	}
+	return b.String()
+}
@@ -0,0 +1,50 @@
+package usegold
+
+import (
+	"bytes"
+	"github.com/monopole/mdparse/internal/mdast"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAst(t *testing.T) {
+	src := []byte("# Title\n\nSee [this](http://x.com).\n\n<!-- @hey -->\n```bash\necho hi\n```\n")
+	doc := Ast(src)
+	assert.Equal(t, "Document", doc.Kind)
+	if !assert.Len(t, doc.Children, 4) {
+		return
+	}
+	h := doc.Children[0]
+	assert.Equal(t, "Heading", h.Kind)
+	assert.Equal(t, "1", h.Props["level"])
+	assert.Equal(t, "title", h.Attributes["id"])
+	assert.Equal(t, &mdast.Range{Start: 2, Stop: 7, Line: 1}, h.Range)
+
+	link := doc.Children[1].Children[1]
+	assert.Equal(t, "Link", link.Kind)
+	assert.Equal(t, "http://x.com", link.Props["destination"])
+	assert.Equal(t, &mdast.Range{Start: 14, Stop: 18, Line: 3}, link.Range)
+
+	assert.Equal(t, "HTMLBlock", doc.Children[2].Kind)
+	assert.Equal(t, "<!-- @hey -->\n", doc.Children[2].Text)
+	cb := doc.Children[3]
+	assert.Equal(t, "FencedCodeBlock", cb.Kind)
+	assert.Equal(t, "bash", cb.Props["info"])
+	assert.Equal(t, "echo hi\n", cb.Text)
+	assert.Equal(t, &mdast.Range{Start: 58, Stop: 66, Line: 7}, cb.Range)
+}
+
+func TestAstWriteText(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t,
+		Ast([]byte("# Title\n\nSome *text*.\n")).Write(&b, mdast.FormatText))
+	assert.Equal(t, `Document [2:21] line 1
+  Heading [2:7] line 1 level=1 {id="title"}
+    Text [2:7] line 1 "Title"
+  Paragraph [9:21] line 3
+    Text [9:14] line 3 "Some "
+    Emphasis [15:19] line 3 level=1
+      Text [15:19] line 3 "text"
+    Text [20:21] line 3 "."
+`, b.String())
+}
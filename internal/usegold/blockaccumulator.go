@@ -1,47 +1,50 @@
 package usegold
 
 import (
-	"fmt"
+	"context"
 	"github.com/monopole/mdparse/internal/loader"
 	"github.com/monopole/mdrip/base"
-	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/ast"
-	"github.com/yuin/goldmark/extension"
-	"github.com/yuin/goldmark/parser"
-	"github.com/yuin/goldmark/renderer/html"
-	"github.com/yuin/goldmark/text"
-	"strings"
+	"runtime"
+	"sync"
 )
 
-// BlockAccumulator uses the goldmark parser to find code blocks.
+// BlockAccumulator finds code blocks, using a Cache of files parsed
+// with goldmark.  VisitFolder parses a folder's files in parallel.
+// An accumulator is safe for concurrent use, though blocks from
+// concurrent visits are kept in the order the visits finish.
 type BlockAccumulator struct {
-	p           goldmark.Markdown
-	currentFile *loader.MyFile
+	// Workers is how many files VisitFolder parses at once.
+	// If not positive, runtime.GOMAXPROCS(0) is used.
+	Workers int
 
-	// The code blocks found in the AST.
+	cache *Cache
+
+	mu sync.Mutex
+	// The code blocks found.
 	blocks []*loader.CodeBlock
 }
 
-func NewBlockAccumulator() *BlockAccumulator {
-	markdown := goldmark.New(
-		goldmark.WithExtensions(extension.GFM),
-		goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
-		),
-		goldmark.WithRendererOptions(
-			html.WithHardWraps(),
-			html.WithXHTML(),
-			html.WithUnsafe(),
-		),
-	)
+// NewBlockAccumulator returns an accumulator with its own cache,
+// parsing with the default options changed by opts, e.g.
+//
+//	NewBlockAccumulator(WithFootnotes(true), WithTables(false))
+func NewBlockAccumulator(opts ...Option) *BlockAccumulator {
+	return NewCachedBlockAccumulator(NewCache(opts...))
+}
+
+// NewCachedBlockAccumulator returns an accumulator using the cache,
+// and so the cache's options.
+func NewCachedBlockAccumulator(c *Cache) *BlockAccumulator {
 	return &BlockAccumulator{
-		p: markdown,
+		cache: c,
 	}
 }
 
 const blanks = "                                                                "
 
 func (v *BlockAccumulator) Blocks(l base.Label) []*loader.CodeBlock {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	var result []*loader.CodeBlock
 	for i := range v.blocks {
 		if v.blocks[i].HasLabel(l) {
@@ -51,53 +54,59 @@ func (v *BlockAccumulator) Blocks(l base.Label) []*loader.CodeBlock {
 	return result
 }
 
-func (v *BlockAccumulator) VisitFolder(fl *loader.MyFolder) {
-	fl.VisitFiles(v)
-	fl.VisitFolders(v)
+// FileBlocks returns all the code blocks in the file.
+// It's a loader.BlockFinder, for use with loader.Diff.
+func FileBlocks(fi *loader.MyFile) []*loader.CodeBlock {
+	return NewCache().FileBlocks(fi)
 }
 
-func (v *BlockAccumulator) VisitFile(fi *loader.MyFile) {
-	v.currentFile = fi
-	// An abstract syntax tree discovered by parsing the content.
-	// Cannot be used alone, as it holds pointers into content.
-	doc := v.p.Parser().Parse(text.NewReader(fi.C()))
-	fmt.Printf("scanning %s\n", fi.Name())
-	ast.Walk(doc, v.walkForBlocks)
-}
+// VisitFolder finds the blocks in all the files below the folder.
+// Files are parsed by a pool of workers, each finding the blocks of
+// one file at a time; the results are kept in tree order.
+func (v *BlockAccumulator) VisitFolder(fl *loader.MyFolder) {
+	var files []*loader.MyFile
+	_ = (&loader.Walker{
+		VisitFile: func(_ *loader.WalkState, fi *loader.MyFile) error {
+			files = append(files, fi)
+			return nil
+		},
+	}).Walk(context.Background(), fl)
 
-func (v *BlockAccumulator) walkForBlocks(n ast.Node, entering bool) (ast.WalkStatus, error) {
-	if !entering {
-		return ast.WalkContinue, nil
+	found := make([][]*loader.CodeBlock, len(files))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for n := min(v.workers(), len(files)); n > 0; n-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				found[i] = v.cache.FileBlocks(files[i])
+			}
+		}()
 	}
-	if n.Kind() == ast.KindFencedCodeBlock {
-		if fcb, ok := n.(*ast.FencedCodeBlock); ok {
-			v.accumulateCodeBlock(fcb)
-		} else {
-			return ast.WalkStop, fmt.Errorf("ast.Kind() is dishonest")
-		}
+	for i := range files {
+		next <- i
 	}
-	return ast.WalkContinue, nil
-}
+	close(next)
+	wg.Wait()
 
-func (v *BlockAccumulator) accumulateCodeBlock(fcb *ast.FencedCodeBlock) {
-	cb := loader.NewCodeBlock(
-		v.currentFile, v.nodeText(fcb), string(fcb.Language(v.currentFile.C())))
-	if prev := fcb.PreviousSibling(); prev != nil && prev.Kind() == ast.KindHTMLBlock {
-		if html, ok := prev.(*ast.HTMLBlock); ok {
-			// We have a preceding HTML block.
-			// If it's an HTML comment, try to extract labels.
-			cb.AddLabels(loader.ParseLabels(loader.CommentBody(v.nodeText(html))))
-		}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, blocks := range found {
+		v.blocks = append(v.blocks, blocks...)
 	}
-	v.blocks = append(v.blocks, cb)
 }
 
-// TODO: Could change this to preserve lines?
-func (v *BlockAccumulator) nodeText(n ast.Node) string {
-	var buff strings.Builder
-	for i := 0; i < n.Lines().Len(); i++ {
-		s := n.Lines().At(i)
-		buff.Write(v.currentFile.C()[s.Start:s.Stop])
+func (v *BlockAccumulator) workers() int {
+	if v.Workers > 0 {
+		return v.Workers
 	}
-	return buff.String()
+	return runtime.GOMAXPROCS(0)
+}
+
+func (v *BlockAccumulator) VisitFile(fi *loader.MyFile) {
+	blocks := v.cache.FileBlocks(fi)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.blocks = append(v.blocks, blocks...)
 }
@@ -0,0 +1,105 @@
+package usegold
+
+import (
+	"fmt"
+	"github.com/monopole/mdparse/internal/loader"
+	"github.com/monopole/mdrip/base"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+// makeTree returns a tree of folders, each holding files, each holding
+// blocks.  Every block's code names where it is, so order can be checked.
+func makeTree(folders, files, blocks int) *loader.MyFolder {
+	root := loader.NewFolder("root")
+	for i := 0; i < folders; i++ {
+		fl := loader.NewFolder(fmt.Sprintf("f%02d", i))
+		for j := 0; j < files; j++ {
+			c := fmt.Sprintf("# File %d %d\n\nSome text.\n\n", i, j)
+			for k := 0; k < blocks; k++ {
+				c += fmt.Sprintf(
+					"<!-- @step%d -->\n```bash\necho %d %d %d\n```\n\n", k, i, j, k)
+			}
+			fl.AddFileObject(loader.NewFile(fmt.Sprintf("file%02d.md", j), []byte(c)))
+		}
+		root.AddFolderObject(fl)
+	}
+	return root
+}
+
+func blockCodes(blocks []*loader.CodeBlock) (result []string) {
+	for _, b := range blocks {
+		result = append(result, b.Code())
+	}
+	return
+}
+
+func TestBlockAccumulatorOrder(t *testing.T) {
+	tree := makeTree(3, 4, 2)
+	var want []string
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 2; k++ {
+				want = append(want, fmt.Sprintf("echo %d %d %d\n", i, j, k))
+			}
+		}
+	}
+	for _, workers := range []int{0, 1, 2, 100} {
+		t.Run(fmt.Sprintf("workers%d", workers), func(t *testing.T) {
+			ba := NewBlockAccumulator()
+			ba.Workers = workers
+			ba.VisitFolder(tree)
+			assert.Equal(t, want, blockCodes(ba.Blocks(base.WildCardLabel)))
+			assert.Len(t, ba.Blocks(base.Label("step1")), 12)
+		})
+	}
+}
+
+func TestBlockAccumulatorEmpty(t *testing.T) {
+	ba := NewBlockAccumulator()
+	ba.VisitFolder(loader.NewFolder("empty"))
+	assert.Empty(t, ba.Blocks(base.WildCardLabel))
+}
+
+func TestBlockAccumulatorConcurrentUse(t *testing.T) {
+	tree := makeTree(4, 5, 3)
+	c := NewCache()
+	ba := NewCachedBlockAccumulator(c)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ba.VisitFolder(tree)
+		}()
+		go func() {
+			defer wg.Done()
+			ba.VisitFile(loader.NewFile("x.md", []byte(labelled)))
+			_ = ba.Blocks(base.WildCardLabel)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, ba.Blocks(base.WildCardLabel), 4*(4*5*3+2))
+	assert.Len(t, ba.Blocks(base.Label("install")), 4)
+	// Each distinct file was parsed once.
+	assert.Equal(t, 4*5+1, c.Stats().Parsed)
+}
+
+func benchmarkVisitFolder(b *testing.B, workers int) {
+	tree := makeTree(20, 25, 5)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ba := NewBlockAccumulator()
+		ba.Workers = workers
+		ba.VisitFolder(tree)
+	}
+}
+
+func BenchmarkVisitFolderSerial(b *testing.B) {
+	benchmarkVisitFolder(b, 1)
+}
+
+func BenchmarkVisitFolderParallel(b *testing.B) {
+	benchmarkVisitFolder(b, 0)
+}
@@ -0,0 +1,328 @@
+package usegold
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"github.com/monopole/mdparse/internal/loader"
+	"github.com/monopole/mdrip/base"
+	"github.com/spf13/afero"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// cacheVersion changes when what's persisted, or how
+	// files are parsed, does.  Older entries are then ignored.
+	cacheVersion = 1
+
+	// DefaultMaxDocuments is how many documents a Cache holds by default.
+	DefaultMaxDocuments = 1000
+)
+
+// Cache holds parsed files, keyed by the digest of their content
+// (see loader.MyFile.Digest), so files are parsed once no matter
+// how many times, or in how many trees, they're visited.
+//
+// Documents, with their syntax trees, are held in memory only, up to
+// MaxDocuments of them.  Just the code blocks found in each file can
+// also be kept on disk (see Persist), so that runs on unchanged files
+// needn't parse them to find blocks; anything needing the syntax tree
+// of such a file parses it again.
+//
+// A Cache is safe for concurrent use.
+type Cache struct {
+	// Options says how files are parsed.
+	// It mustn't be changed once the cache is used.
+	Options Options
+
+	// MaxDocuments, if positive, is the most documents held in memory.
+	// When there are more, the least recently used are dropped.
+	// It mustn't be changed once the cache is used.
+	MaxDocuments int
+
+	// parsers holds goldmark.Markdown instances, so that
+	// files can be parsed concurrently.
+	parsers sync.Pool
+
+	mu sync.Mutex
+	// docs maps keys to elements of lru, which hold documents,
+	// the most recently used first.
+	docs  map[string]*list.Element
+	lru   *list.List
+	stats CacheStats
+	fs    *afero.Afero
+	dir   string
+}
+
+// CacheStats counts what a Cache has done.
+type CacheStats struct {
+	// Hits counts requests for a document already in memory.
+	Hits int
+	// Parsed counts files parsed.
+	Parsed int
+	// Loaded counts files whose blocks were read from disk.
+	Loaded int
+	// Dropped counts documents dropped to stay within MaxDocuments.
+	Dropped int
+}
+
+// NewCache returns a cache parsing with the
+// default options changed by opts.
+func NewCache(opts ...Option) *Cache {
+	c := &Cache{
+		Options:      NewOptions(opts...),
+		MaxDocuments: DefaultMaxDocuments,
+		docs:         make(map[string]*list.Element),
+		lru:          list.New(),
+	}
+	c.parsers.New = func() any { return newMarkdown(c.Options) }
+	return c
+}
+
+// Persist arranges for code blocks to be kept in the directory,
+// which is made if need be.  Entries that can't be read or written
+// are ignored; the files involved are simply parsed.
+func (c *Cache) Persist(fs afero.Fs, dir string) error {
+	if err := fs.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("unable to make cache directory; %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fs, c.dir = &afero.Afero{Fs: fs}, dir
+	return nil
+}
+
+// Stats returns what the cache has done so far.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Document returns the parsed form of the file.
+// Parsing is put off until the document is used.
+func (c *Cache) Document(fi *loader.MyFile) *Document {
+	key := fi.Digest()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.docs[key]; ok {
+		c.stats.Hits++
+		c.lru.MoveToFront(e)
+		return e.Value.(*Document)
+	}
+	d := &Document{cache: c, key: key, c: fi.C()}
+	c.docs[key] = c.lru.PushFront(d)
+	for c.MaxDocuments > 0 && c.lru.Len() > c.MaxDocuments {
+		c.dropLocked(c.lru.Back())
+		c.stats.Dropped++
+	}
+	return d
+}
+
+// Forget drops the file's document from memory, if it's there.
+// Its blocks stay on disk.
+func (c *Cache) Forget(fi *loader.MyFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.docs[fi.Digest()]; ok {
+		c.dropLocked(e)
+	}
+}
+
+// Len returns the number of documents held in memory.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// dropLocked drops a document; c.mu must be held.
+func (c *Cache) dropLocked(e *list.Element) {
+	delete(c.docs, e.Value.(*Document).key)
+	c.lru.Remove(e)
+}
+
+// FileBlocks returns all the code blocks in the file.
+// It's a loader.BlockFinder, for use with loader.Diff.
+func (c *Cache) FileBlocks(fi *loader.MyFile) []*loader.CodeBlock {
+	return c.Document(fi).Blocks(fi)
+}
+
+// HasBlockWithLabel returns a function, for use with MyFolder.Filter,
+// that's true if the file has a code block with the given label.
+func (c *Cache) HasBlockWithLabel(l base.Label) func(*loader.MyFile) bool {
+	return func(fi *loader.MyFile) bool {
+		for _, b := range c.FileBlocks(fi) {
+			if b.HasLabel(l) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Document is a parsed markdown file.
+type Document struct {
+	cache *Cache
+	key   string
+	c     []byte
+
+	mu sync.Mutex
+	// root is nil until the file is parsed.
+	root ast.Node
+	// blocks is nil until the file is parsed or its blocks are loaded.
+	blocks []blockRecord
+}
+
+// blockRecord is what's kept of a code block.
+type blockRecord struct {
+	Code     string       `json:"code"`
+	Language string       `json:"language,omitempty"`
+	Labels   []base.Label `json:"labels,omitempty"`
+}
+
+// cacheEntry is what's kept on disk for a file.
+type cacheEntry struct {
+	Version int           `json:"version"`
+	Blocks  []blockRecord `json:"blocks"`
+}
+
+// Source returns the markdown the document was parsed from.
+// The document's nodes hold offsets into it.
+func (d *Document) Source() []byte {
+	return d.c
+}
+
+// Root returns the document's syntax tree, parsing the file if need be.
+// The tree is shared, so it mustn't be modified.
+func (d *Document) Root() ast.Node {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.root == nil {
+		d.parse()
+	}
+	return d.root
+}
+
+// Blocks returns the code blocks in the document,
+// as blocks of the given file, which should have the
+// document's content.
+func (d *Document) Blocks(fi *loader.MyFile) []*loader.CodeBlock {
+	d.mu.Lock()
+	if d.blocks == nil && !d.load() {
+		d.parse()
+	}
+	records := d.blocks
+	d.mu.Unlock()
+	result := make([]*loader.CodeBlock, len(records))
+	for i, r := range records {
+		result[i] = loader.NewCodeBlock(fi, r.Code, r.Language)
+		result[i].AddLabels(r.Labels)
+	}
+	return result
+}
+
+// parse parses the document, finding its blocks, and keeps them on disk.
+func (d *Document) parse() {
+	md := d.cache.parsers.Get().(goldmark.Markdown)
+	d.root = md.Parser().Parse(text.NewReader(d.c))
+	d.cache.parsers.Put(md)
+	if d.blocks == nil {
+		d.blocks = findBlocks(d.c, d.root)
+		d.save()
+	}
+	d.cache.mu.Lock()
+	d.cache.stats.Parsed++
+	d.cache.mu.Unlock()
+}
+
+func (d *Document) path() (*afero.Afero, string) {
+	d.cache.mu.Lock()
+	defer d.cache.mu.Unlock()
+	if d.cache.fs == nil {
+		return nil, ""
+	}
+	return d.cache.fs, filepath.Join(
+		d.cache.dir, d.key+"-"+d.cache.Options.parseKey()+".json")
+}
+
+// load reads the document's blocks from disk, if they're there.
+func (d *Document) load() bool {
+	fs, p := d.path()
+	if fs == nil {
+		return false
+	}
+	c, err := fs.ReadFile(p)
+	if err != nil {
+		return false
+	}
+	var e cacheEntry
+	if err = json.Unmarshal(c, &e); err != nil || e.Version != cacheVersion {
+		return false
+	}
+	d.blocks = e.Blocks
+	if d.blocks == nil {
+		d.blocks = []blockRecord{}
+	}
+	d.cache.mu.Lock()
+	d.cache.stats.Loaded++
+	d.cache.mu.Unlock()
+	return true
+}
+
+// save writes the document's blocks to disk, if persisting.
+func (d *Document) save() {
+	fs, p := d.path()
+	if fs == nil {
+		return
+	}
+	c, err := json.Marshal(&cacheEntry{Version: cacheVersion, Blocks: d.blocks})
+	if err != nil {
+		return
+	}
+	_ = fs.WriteFile(p, c, 0o644)
+}
+
+// findBlocks returns the fenced code blocks in the tree, with
+// labels taken from an HTML comment just before each block.
+func findBlocks(src []byte, root ast.Node) []blockRecord {
+	result := []blockRecord{}
+	_ = ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n.Kind() != ast.KindFencedCodeBlock {
+			return ast.WalkContinue, nil
+		}
+		fcb, ok := n.(*ast.FencedCodeBlock)
+		if !ok {
+			return ast.WalkStop, fmt.Errorf("ast.Kind() is dishonest")
+		}
+		r := blockRecord{
+			Code:     nodeText(src, fcb),
+			Language: string(fcb.Language(src)),
+		}
+		if prev := fcb.PreviousSibling(); prev != nil && prev.Kind() == ast.KindHTMLBlock {
+			if html, ok := prev.(*ast.HTMLBlock); ok {
+				// We have a preceding HTML block.
+				// If it's an HTML comment, try to extract labels.
+				r.Labels = loader.ParseLabels(loader.CommentBody(nodeText(src, html)))
+			}
+		}
+		result = append(result, r)
+		return ast.WalkContinue, nil
+	})
+	return result
+}
+
+// TODO: Could change this to preserve lines?
+func nodeText(src []byte, n ast.Node) string {
+	var buff strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		s := n.Lines().At(i)
+		buff.Write(src[s.Start:s.Stop])
+	}
+	return buff.String()
+}
@@ -0,0 +1,93 @@
+package usegold
+
+import (
+	"github.com/monopole/mdparse/internal/loader"
+	"github.com/monopole/mdrip/base"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+const labelled = "# hey\n\n<!-- @install -->\n```bash\nmake\n```\n\n```\nls\n```\n"
+
+func blockSummaries(blocks []*loader.CodeBlock) (result []string) {
+	for _, b := range blocks {
+		result = append(result, b.Name()+"|"+b.Language()+"|"+b.Code())
+	}
+	return
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache()
+	f1 := loader.NewFile("a.md", []byte(labelled))
+	f2 := loader.NewFile("b.md", []byte(labelled))
+	b1, b2 := c.FileBlocks(f1), c.FileBlocks(f2)
+	want := []string{"install|bash|make\n", loader.AnonBlockName + "||ls\n"}
+	assert.Equal(t, want, blockSummaries(b1))
+	assert.Equal(t, want, blockSummaries(b2))
+	assert.Same(t, f2, b2[0].Parent())
+	assert.Equal(t, CacheStats{Hits: 1, Parsed: 1}, c.Stats())
+
+	d := c.Document(f1)
+	assert.Equal(t, "Document", d.Root().Kind().String())
+	assert.Equal(t, []byte(labelled), d.Source())
+	assert.Equal(t, CacheStats{Hits: 2, Parsed: 1}, c.Stats())
+
+	assert.True(t, c.HasBlockWithLabel(base.Label("install"))(f1))
+	assert.False(t, c.HasBlockWithLabel(base.Label("test"))(f1))
+}
+
+func TestCachePersist(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	fi := loader.NewFile("a.md", []byte(labelled))
+	empty := loader.NewFile("b.md", []byte("# no blocks\n"))
+
+	c := NewCache()
+	assert.NoError(t, c.Persist(fs, "/cache"))
+	want := blockSummaries(c.FileBlocks(fi))
+	assert.Empty(t, c.FileBlocks(empty))
+	assert.Equal(t, CacheStats{Parsed: 2}, c.Stats())
+
+	// A later run finds the blocks on disk.
+	c = NewCache()
+	assert.NoError(t, c.Persist(fs, "/cache"))
+	assert.Equal(t, want, blockSummaries(c.FileBlocks(fi)))
+	assert.Empty(t, c.FileBlocks(empty))
+	assert.Equal(t, CacheStats{Loaded: 2}, c.Stats())
+	assert.True(t, c.FileBlocks(fi)[0].HasLabel(base.Label("install")))
+
+	// The tree is still available, by parsing.
+	assert.NotNil(t, c.Document(fi).Root())
+	assert.Equal(t, 1, c.Stats().Parsed)
+
+	// Unreadable entries are ignored.
+	assert.NoError(t, afero.WriteFile(
+		fs, filepath.Join("/cache", fi.Digest()+"-"+c.Options.parseKey()+".json"), []byte("junk"), 0o644))
+	c = NewCache()
+	assert.NoError(t, c.Persist(fs, "/cache"))
+	assert.Equal(t, want, blockSummaries(c.FileBlocks(fi)))
+	assert.Equal(t, CacheStats{Parsed: 1}, c.Stats())
+}
+
+func TestCacheBound(t *testing.T) {
+	c := NewCache()
+	c.MaxDocuments = 2
+	a := loader.NewFile("a.md", []byte("# a\n"))
+	b := loader.NewFile("b.md", []byte("# b\n"))
+	x := loader.NewFile("x.md", []byte("# x\n"))
+	da := c.Document(a)
+	c.Document(b)
+	assert.Same(t, da, c.Document(a))
+	// b is the least recently used.
+	c.Document(x)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, CacheStats{Hits: 1, Dropped: 1}, c.Stats())
+	assert.Same(t, da, c.Document(a))
+
+	// A dropped document still works for those holding it.
+	c.Forget(a)
+	assert.Equal(t, 1, c.Len())
+	assert.NotNil(t, da.Root())
+	assert.NotSame(t, da, c.Document(a))
+}
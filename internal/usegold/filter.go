@@ -0,0 +1,12 @@
+package usegold
+
+import (
+	"github.com/monopole/mdparse/internal/loader"
+	"github.com/monopole/mdrip/base"
+)
+
+// HasBlockWithLabel returns a function, for use with MyFolder.Filter,
+// that's true if the file has a code block with the given label.
+func HasBlockWithLabel(l base.Label) func(*loader.MyFile) bool {
+	return NewCache().HasBlockWithLabel(l)
+}
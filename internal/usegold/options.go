@@ -0,0 +1,128 @@
+package usegold
+
+import (
+	"fmt"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// Options says which goldmark extensions and settings are used,
+// since different doc repos follow different markdown dialects.
+// Strikethrough, autolinks, task lists and heading IDs are always on.
+type Options struct {
+	// Footnotes enables footnotes, e.g. "text[^1]" and "[^1]: note".
+	Footnotes bool
+	// DefinitionLists enables PHP Markdown Extra definition lists.
+	DefinitionLists bool
+	// Typographer replaces quotes, dashes and ellipses with
+	// their typographic equivalents.
+	Typographer bool
+	// Tables enables GFM tables.
+	Tables bool
+	// UnsafeHTML renders raw HTML and risky links as they are,
+	// rather than omitting them.  It doesn't affect parsing.
+	UnsafeHTML bool
+}
+
+// Option changes Options.
+type Option func(*Options)
+
+// DefaultOptions returns the options used if none are given:
+// GitHub flavored markdown, with raw HTML rendered.
+func DefaultOptions() Options {
+	return Options{
+		Tables:     true,
+		UnsafeHTML: true,
+	}
+}
+
+// NewOptions returns the default options changed by opts.
+func NewOptions(opts ...Option) Options {
+	o := DefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithOptions replaces all options, e.g. with options set from flags.
+func WithOptions(o Options) Option {
+	return func(p *Options) {
+		*p = o
+	}
+}
+
+func WithFootnotes(on bool) Option {
+	return func(o *Options) {
+		o.Footnotes = on
+	}
+}
+
+func WithDefinitionLists(on bool) Option {
+	return func(o *Options) {
+		o.DefinitionLists = on
+	}
+}
+
+func WithTypographer(on bool) Option {
+	return func(o *Options) {
+		o.Typographer = on
+	}
+}
+
+func WithTables(on bool) Option {
+	return func(o *Options) {
+		o.Tables = on
+	}
+}
+
+func WithUnsafeHTML(on bool) Option {
+	return func(o *Options) {
+		o.UnsafeHTML = on
+	}
+}
+
+// parseKey distinguishes options that parse differently,
+// so persisted blocks are only used with the options that found them.
+func (o Options) parseKey() string {
+	var bits int
+	for i, on := range []bool{
+		o.Footnotes, o.DefinitionLists, o.Typographer, o.Tables} {
+		if on {
+			bits |= 1 << i
+		}
+	}
+	return fmt.Sprintf("%x", bits)
+}
+
+// newMarkdown returns goldmark configured by the options.
+func newMarkdown(o Options) goldmark.Markdown {
+	exts := []goldmark.Extender{
+		extension.Linkify, extension.Strikethrough, extension.TaskList}
+	if o.Tables {
+		exts = append(exts, extension.Table)
+	}
+	if o.Footnotes {
+		exts = append(exts, extension.Footnote)
+	}
+	if o.DefinitionLists {
+		exts = append(exts, extension.DefinitionList)
+	}
+	if o.Typographer {
+		exts = append(exts, extension.Typographer)
+	}
+	rOpts := []renderer.Option{html.WithHardWraps(), html.WithXHTML()}
+	if o.UnsafeHTML {
+		rOpts = append(rOpts, html.WithUnsafe())
+	}
+	return goldmark.New(
+		goldmark.WithExtensions(exts...),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+		),
+		goldmark.WithRendererOptions(rOpts...),
+	)
+}
@@ -0,0 +1,116 @@
+package usegold
+
+import (
+	"bytes"
+	"github.com/monopole/mdparse/internal/loader"
+	"github.com/monopole/mdparse/internal/mdast"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func kinds(n *mdast.Node) (result []string) {
+	result = append(result, n.Kind)
+	for _, ch := range n.Children {
+		result = append(result, kinds(ch)...)
+	}
+	return
+}
+
+func TestNewOptions(t *testing.T) {
+	assert.Equal(t, DefaultOptions(), NewOptions())
+	assert.Equal(t,
+		Options{Footnotes: true, DefinitionLists: true, Typographer: true},
+		NewOptions(
+			WithFootnotes(true), WithDefinitionLists(true),
+			WithTypographer(true), WithTables(false), WithUnsafeHTML(false)))
+	o := Options{Footnotes: true}
+	assert.Equal(t, o, NewOptions(WithTables(false), WithOptions(o)))
+}
+
+func TestOptionsExtensions(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		kind string
+		opt  func(bool) Option
+		dflt bool
+	}{
+		"footnotes": {
+			src:  "Hey[^1].\n\n[^1]: A note.\n",
+			kind: "Footnote",
+			opt:  WithFootnotes,
+		},
+		"definitionLists": {
+			src:  "Apple\n: A fruit.\n",
+			kind: "DefinitionList",
+			opt:  WithDefinitionLists,
+		},
+		"typographer": {
+			src:  "It's \"quoted\"...\n",
+			kind: "String",
+			opt:  WithTypographer,
+		},
+		"tables": {
+			src:  "| a | b |\n|---|---|\n| 1 | 2 |\n",
+			kind: "Table",
+			opt:  WithTables,
+			dflt: true,
+		},
+	}
+	for n, tc := range tests {
+		t.Run(n, func(t *testing.T) {
+			assert.Equal(t, tc.dflt, contains(kinds(Ast([]byte(tc.src))), tc.kind))
+			assert.True(t, contains(kinds(Ast([]byte(tc.src), tc.opt(true))), tc.kind))
+			assert.False(t, contains(kinds(Ast([]byte(tc.src), tc.opt(false))), tc.kind))
+		})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOptionsUnsafeHTML(t *testing.T) {
+	src := []byte("<b>bold</b>\n")
+	var buff bytes.Buffer
+	assert.NoError(t, newMarkdown(DefaultOptions()).Convert(src, &buff))
+	assert.Contains(t, buff.String(), "<b>bold</b>")
+	buff.Reset()
+	assert.NoError(t, newMarkdown(NewOptions(WithUnsafeHTML(false))).Convert(src, &buff))
+	assert.NotContains(t, buff.String(), "<b>")
+	assert.Equal(t,
+		DefaultOptions().parseKey(), NewOptions(WithUnsafeHTML(false)).parseKey())
+}
+
+func TestOptionsFootnoteBlocks(t *testing.T) {
+	src := []byte("Hey[^1].\n\n[^1]: A note.\n\n    ```\n    ls\n    ```\n")
+	fi := loader.NewFile("a.md", src)
+	assert.Len(t, NewBlockAccumulator(WithFootnotes(true)).cache.FileBlocks(fi), 1)
+	assert.Empty(t, NewBlockAccumulator().cache.FileBlocks(fi))
+}
+
+func TestCachePersistOptions(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	fi := loader.NewFile("a.md", []byte(labelled))
+
+	c := NewCache()
+	assert.NoError(t, c.Persist(fs, "/cache"))
+	c.FileBlocks(fi)
+	assert.Equal(t, CacheStats{Parsed: 1}, c.Stats())
+
+	// Blocks found with other options aren't used.
+	c = NewCache(WithFootnotes(true))
+	assert.NoError(t, c.Persist(fs, "/cache"))
+	c.FileBlocks(fi)
+	assert.Equal(t, CacheStats{Parsed: 1}, c.Stats())
+
+	c = NewCache(WithFootnotes(true))
+	assert.NoError(t, c.Persist(fs, "/cache"))
+	c.FileBlocks(fi)
+	assert.Equal(t, CacheStats{Loaded: 1}, c.Stats())
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/monopole/shexec"
 	"github.com/monopole/shexec/channeler"
 	"github.com/spf13/afero"
+	"io"
 	"os"
 	"time"
 
@@ -47,17 +48,48 @@ func main() {
 }
 
 func newCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:     "mdparse {fileName}",
-		Short:   shortHelp,
-		Long:    shortHelp + " " + version,
-		Example: "  mdparse some/directory",
+	var (
+		ldr             = loader.NewFsLoader(afero.NewOsFs())
+		cache           = usegold.NewCache()
+		cacheDir        string
+		clearCloneCache bool
+	)
+	c := &cobra.Command{
+		Use:   "mdparse {fileName|-}",
+		Short: shortHelp,
+		Long: shortHelp + " " + version + "\n\n" +
+			"Arguments are files, folders, - for stdin, or git repositories,\n" +
+			"e.g. gh:ORG/REPO@REF/PATH.  Without a .git suffix a repository\n" +
+			"name is taken to be ORG/REPO, so other names, including file://\n" +
+			"paths, need the suffix, e.g. file:///srv/git/docs.git/PATH.\n" +
+			"A REF can't hold a /; a branch like release/v1 is read as the\n" +
+			"ref release and the path v1.",
+		Example: "  mdparse some/directory\n" +
+			"  mdparse ../mdrip@v1.0.1/docs\n" +
+			"  mdparse file:///srv/git/docs.git@v1.0.1/guide\n" +
+			"  curl -s https://example.com/doc.md | mdparse -",
+		Args: cobra.ArbitraryArgs,
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			if clearCloneCache {
+				if err := ldr.ClearCloneCache(); err != nil {
+					return err
+				}
+			}
+			if cacheDir == "" {
+				return nil
+			}
+			return cache.Persist(afero.NewOsFs(), cacheDir)
+		},
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
 			var fld *loader.MyFolder
-			fld, err = loadData(args)
+			fld, err = loadData(ldr, args)
+			reportDiagnostics(cmd.ErrOrStderr(), ldr)
 			if err != nil {
 				return err
 			}
+			if fld == nil {
+				return fmt.Errorf("no markdown loaded from %v", args)
+			}
 			loader.NewVisitorDump().VisitFolder(fld)
 			var blocks []*loader.CodeBlock
 			if useGoldmark := true; useGoldmark {
@@ -75,7 +107,7 @@ func newCommand() *cobra.Command {
 				//   - There are some PRs being ignored by the maintainer.
 				//   - It doesn't yet support block level attributes, but is thinking about it
 				//
-				ba := usegold.NewBlockAccumulator()
+				ba := usegold.NewCachedBlockAccumulator(cache)
 				ba.VisitFolder(fld)
 				blocks = ba.Blocks(base.WildCardLabel)
 
@@ -132,10 +164,81 @@ func newCommand() *cobra.Command {
 
 		SilenceUsage: true,
 	}
+	c.PersistentFlags().BoolVar(
+		&ldr.RecurseSubmodules, "submodules", false,
+		"Also clone the submodules of cloned repositories.")
+	c.PersistentFlags().DurationVar(
+		&ldr.CloneTimeout, "clone-timeout", loader.DefaultCloneTimeout,
+		"How long cloning a repository may take; zero means no limit.")
+	c.PersistentFlags().StringVar(
+		&ldr.DisplayRoot, "display-root", "",
+		"A friendly name to show for a loaded folder, e.g. instead of ../shared-docs.")
+	c.PersistentFlags().StringVar(
+		&ldr.CloneCacheDir, "clone-cache-dir", ldr.CloneCacheDir,
+		"Where to keep cloned repositories; if empty, they're cloned on every run.")
+	c.PersistentFlags().DurationVar(
+		&ldr.CloneCacheMaxAge, "clone-cache-max-age", ldr.CloneCacheMaxAge,
+		"How long a cached clone may go unused before it's removed; zero means forever.")
+	c.PersistentFlags().BoolVar(
+		&clearCloneCache, "clear-clone-cache", false,
+		"Remove all cached clones before doing anything else.")
+	c.PersistentFlags().BoolVar(
+		&ldr.ContinueOnError, "keep-going", false,
+		"Skip files and folders that can't be loaded, and summarize the problems.")
+	c.PersistentFlags().Int64Var(
+		&ldr.MaxFileSize, "max-file-size", ldr.MaxFileSize,
+		"The size in bytes of the largest file to load; larger files are skipped.")
+	c.PersistentFlags().IntVar(
+		&ldr.MaxDepth, "max-depth", ldr.MaxDepth,
+		"How many levels of folders to load; deeper folders are skipped.")
+	c.PersistentFlags().IntVar(
+		&ldr.MaxFiles, "max-files", ldr.MaxFiles,
+		"The most files to load from one argument.")
+	c.PersistentFlags().Int64Var(
+		&ldr.MaxTotalBytes, "max-total-bytes", ldr.MaxTotalBytes,
+		"The most bytes to load from one argument.")
+	c.PersistentFlags().StringVar(
+		&cacheDir, "cache-dir", "",
+		"Where to keep the code blocks found in files, so unchanged files needn't be parsed to find them.")
+	c.PersistentFlags().BoolVar(
+		&cache.Options.Footnotes, "footnotes", cache.Options.Footnotes,
+		"Parse footnotes.")
+	c.PersistentFlags().BoolVar(
+		&cache.Options.DefinitionLists, "definition-lists", cache.Options.DefinitionLists,
+		"Parse definition lists.")
+	c.PersistentFlags().BoolVar(
+		&cache.Options.Typographer, "typographer", cache.Options.Typographer,
+		"Replace quotes, dashes and ellipses with typographic ones.")
+	c.PersistentFlags().BoolVar(
+		&cache.Options.Tables, "tables", cache.Options.Tables,
+		"Parse GitHub flavored markdown tables.")
+	c.PersistentFlags().BoolVar(
+		&cache.Options.UnsafeHTML, "unsafe-html", cache.Options.UnsafeHTML,
+		"Render raw HTML as is; if false, it's omitted.")
+	c.AddCommand(
+		newAstCommand(ldr, cache),
+		newDiffCommand(ldr, cache),
+		newDumpCommand(ldr, cache),
+		newManifestCommand(ldr),
+		newSnapshotCommand(ldr),
+		newExportCommand(ldr, cache))
+	return c
+}
+
+// reportDiagnostics writes what the loader skipped, if anything.
+func reportDiagnostics(w io.Writer, ldr *loader.FsLoader) {
+	ds := ldr.Diagnostics()
+	if len(ds) == 0 {
+		return
+	}
+	for _, d := range ds {
+		fmt.Fprintln(w, "skipped", d)
+	}
+	fmt.Fprintln(w, ds.Summary())
+	ldr.ResetDiagnostics()
 }
 
-func loadData(args []string) (*loader.MyFolder, error) {
-	ldr := loader.NewFsLoader(afero.NewOsFs())
+func loadData(ldr *loader.FsLoader, args []string) (*loader.MyFolder, error) {
 	if len(args) < 2 {
 		arg := "." // By default, read the current directory.
 		if len(args) == 1 {
@@ -144,13 +247,11 @@ func loadData(args []string) (*loader.MyFolder, error) {
 		return ldr.LoadTree(arg)
 	}
 	// Make one folder to hold all the argument folders.
-	wrapper := loader.NewFolder("multiArgWrapper")
+	ws := loader.NewWorkspace(ldr)
 	for i := range args {
-		fld, err := ldr.LoadTree(args[i])
-		if err != nil {
+		if _, err := ws.Add(args[i]); err != nil {
 			return nil, err
 		}
-		wrapper.AddFolderObject(fld)
 	}
-	return wrapper, nil
+	return ws.Folder(), nil
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"github.com/monopole/mdparse/internal/loader"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+func newManifestCommand(ldr *loader.FsLoader) *cobra.Command {
+	var check string
+	c := &cobra.Command{
+		Use:   "manifest [--check {manifestFile}] [{fileName|-} ...]",
+		Short: "Write a JSON manifest of the tree's files, with content digests.",
+		Long: "Write a JSON manifest of the tree's files, with content digests.\n\n" +
+			"With --check, instead compare the tree with the given manifest, listing\n" +
+			"the paths that differ, and fail if any do.",
+		Example: "  mdparse manifest docs > manifest.json\n" +
+			"  mdparse manifest --check manifest.json gh:monopole/mdrip/data",
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fld, err := loadData(ldr, args)
+			reportDiagnostics(cmd.ErrOrStderr(), ldr)
+			if err != nil {
+				return err
+			}
+			if fld == nil {
+				fld = loader.NewFolder("")
+			}
+			m := loader.NewManifest(fld)
+			if check == "" {
+				return m.Write(cmd.OutOrStdout())
+			}
+			f, err := os.Open(check)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			want, err := loader.ReadManifest(f)
+			if err != nil {
+				return fmt.Errorf("unable to read manifest %q; %w", check, err)
+			}
+			bad := want.Mismatches(m)
+			for _, p := range bad {
+				if p == "" {
+					p = "(order of top level files or folders)"
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), p)
+			}
+			if len(bad) > 0 {
+				return fmt.Errorf("tree doesn't match %q", check)
+			}
+			return nil
+		},
+		SilenceUsage: true,
+	}
+	c.Flags().StringVar(
+		&check, "check", "",
+		"A manifest to compare with the tree.")
+	return c
+}
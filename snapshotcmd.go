@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"github.com/monopole/mdparse/internal/loader"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+func newSnapshotCommand(ldr *loader.FsLoader) *cobra.Command {
+	var (
+		output string
+		format string
+	)
+	c := &cobra.Command{
+		Use:   "snapshot [--output {file}] [{fileName|-} ...]",
+		Short: "Write a snapshot of the tree, to be loaded later without the source.",
+		Long: "Write a snapshot of the tree, to be loaded later without the source.\n\n" +
+			"Any command accepts a snapshot in place of a folder, if the snapshot's name\n" +
+			"ends in " + loader.SnapshotExtJSON + " or " + loader.SnapshotExtGob + ".",
+		Example: "  mdparse snapshot --output docs" + loader.SnapshotExtGob + " gh:monopole/mdrip/data\n" +
+			"  mdparse docs" + loader.SnapshotExtGob,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			f := loader.SnapshotJSON
+			switch format {
+			case "":
+				if output != "" {
+					f, _ = loader.SnapshotFormatOf(output)
+				}
+			case "json":
+			case "gob":
+				f = loader.SnapshotGob
+			default:
+				return fmt.Errorf("unknown format %q; use json or gob", format)
+			}
+			fld, err := loadData(ldr, args)
+			reportDiagnostics(cmd.ErrOrStderr(), ldr)
+			if err != nil {
+				return err
+			}
+			if fld == nil {
+				fld = loader.NewFolder("")
+			}
+			var w io.Writer = cmd.OutOrStdout()
+			if output != "" {
+				var file *os.File
+				if file, err = os.Create(output); err != nil {
+					return err
+				}
+				defer func() {
+					if cErr := file.Close(); err == nil {
+						err = cErr
+					}
+				}()
+				w = file
+			}
+			return fld.WriteSnapshot(w, f)
+		},
+		SilenceUsage: true,
+	}
+	c.Flags().StringVarP(
+		&output, "output", "o", "",
+		"Where to write the snapshot; by default, standard output.")
+	c.Flags().StringVar(
+		&format, "format", "",
+		"json or gob; by default, implied by the output file's name, else json.")
+	return c
+}